@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"github.com/micah5/yamlx"
+)
+
+// diff renders two files and prints how their output differs.
+func (a *app) diff(args []string) error {
+	fs := a.newFlagSet("diff", "[-o text|json] [--set key=value]... [--seed n] <old> <new>")
+	format := fs.String("o", "text", "output format: text or json")
+	vars := varsFlag{}
+	fs.Var(vars, "set", "set a variable on both sides, overriding the anchor of the same name (repeatable)")
+	seed := fs.Int64("seed", 0, "seed for rand() and the other random functions on both sides; 0 derives one from the old file")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("yamlx: unknown output format %q", *format)
+	}
+
+	oldData, err := a.readInput(positional[0])
+	if err != nil {
+		return err
+	}
+	newData, err := a.readInput(positional[1])
+	if err != nil {
+		return err
+	}
+	changes, err := yamlx.Diff(oldData, newData, yamlx.Options{Vars: vars, Seed: *seed})
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		if changes == nil {
+			changes = []yamlx.Change{}
+		}
+		err = a.writeJSON(changes)
+	} else {
+		for _, change := range changes {
+			fmt.Fprintln(a.stdout, change)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		return errFindings
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"github.com/micah5/yamlx"
+)
+
+// eval evaluates one expression against the anchors of a file.
+func (a *app) eval(args []string) error {
+	fs := a.newFlagSet("eval", "[--set key=value]... <expression> [file]")
+	vars := varsFlag{}
+	fs.Var(vars, "set", "set a variable, overriding the anchor of the same name (repeatable)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 || len(positional) > 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	data, err := a.readInput(first(positional[1:]))
+	if err != nil {
+		return err
+	}
+	result, err := yamlx.Eval(data, positional[0], yamlx.Options{Vars: vars})
+	if err != nil {
+		return err
+	}
+	return printValue(a, result)
+}
+
+// printValue prints scalars as they are and lists and maps as json.
+func printValue(a *app, value any) error {
+	switch value.(type) {
+	case []any, map[string]any, *yamlx.OrderedMap:
+		output, err := yamlx.EncodeJSON(value, "")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(a.stdout, string(output))
+	default:
+		fmt.Fprintln(a.stdout, value)
+	}
+	return nil
+}
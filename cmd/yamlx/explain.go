@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/micah5/yamlx"
+)
+
+// explain evaluates a template and prints each step of the evaluation.
+func (a *app) explain(args []string) error {
+	fs := a.newFlagSet("explain", "[--json] [--set key=value]... [file]")
+	asJSON := fs.Bool("json", false, "print the steps as json instead of a tree")
+	vars := varsFlag{}
+	fs.Var(vars, "set", "set a variable, overriding the anchor of the same name (repeatable)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	data, err := a.readInput(first(positional))
+	if err != nil {
+		return err
+	}
+	trace := &yamlx.Trace{}
+	_, evalErr := yamlx.RenderJSON(data, yamlx.Options{Vars: vars, Trace: trace})
+	// The steps up to a failure are what explain it, so print them either way
+	if *asJSON {
+		if err := a.writeJSON(trace); err != nil {
+			return err
+		}
+	} else {
+		trace.Fprint(a.stdout)
+	}
+	return evalErr
+}
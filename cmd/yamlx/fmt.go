@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/micah5/yamlx"
+	"os"
+)
+
+// fmt formats files, printing the result, writing it back with -w, or with
+// --check listing the files that aren't formatted.
+func (a *app) fmt(args []string) error {
+	fs := a.newFlagSet("fmt", "[--check] [-w] [file]...")
+	check := fs.Bool("check", false, "list files that aren't formatted and exit with status 1 if there are any")
+	write := fs.Bool("w", false, "write the result back to the files instead of printing it")
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	unformatted := false
+	for _, file := range files {
+		data, err := a.readInput(file)
+		if err != nil {
+			return err
+		}
+		formatted, err := yamlx.Format(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		switch {
+		case *check:
+			if !bytes.Equal(data, formatted) {
+				unformatted = true
+				fmt.Fprintln(a.stdout, file)
+			}
+		case *write && file != "-":
+			if !bytes.Equal(data, formatted) {
+				if err := os.WriteFile(file, formatted, 0o644); err != nil {
+					return err
+				}
+			}
+		default:
+			if _, err := a.stdout.Write(formatted); err != nil {
+				return err
+			}
+		}
+	}
+	if unformatted {
+		return errFindings
+	}
+	return nil
+}
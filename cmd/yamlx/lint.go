@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/micah5/yamlx"
+)
+
+// errFindings makes lint, fmt --check and diff exit with status 1 once their
+// findings are printed.
+var errFindings = errors.New("findings")
+
+// fileDiagnostic is a diagnostic along with the file it was found in.
+type fileDiagnostic struct {
+	File string `json:"file"`
+	yamlx.Diagnostic
+}
+
+// lint checks files for problems and prints what it finds.
+func (a *app) lint(args []string) error {
+	fs := a.newFlagSet("lint", "[--format text|json|sarif] [file]...")
+	format := fs.String("format", "text", "output format: text, json or sarif")
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" && *format != "sarif" {
+		return fmt.Errorf("yamlx: unknown lint format %q", *format)
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	findings := []fileDiagnostic{}
+	for _, file := range files {
+		data, err := a.readInput(file)
+		if err != nil {
+			return err
+		}
+		name := file
+		if file == "-" {
+			name = "<stdin>"
+		}
+		for _, diagnostic := range yamlx.Lint(data) {
+			findings = append(findings, fileDiagnostic{name, diagnostic})
+		}
+	}
+
+	switch *format {
+	case "text":
+		for _, f := range findings {
+			fmt.Fprintf(a.stdout, "%s:%s\n", f.File, f.Diagnostic)
+		}
+	case "json":
+		err = a.writeJSON(findings)
+	case "sarif":
+		err = a.writeJSON(sarifLog(findings))
+	}
+	if err != nil {
+		return err
+	}
+	if len(findings) > 0 {
+		return errFindings
+	}
+	return nil
+}
+
+func (a *app) writeJSON(v any) error {
+	encoder := json.NewEncoder(a.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// sarifLog builds a SARIF 2.1.0 log, the format CI systems like GitHub code
+// scanning read annotations from.
+func sarifLog(findings []fileDiagnostic) map[string]any {
+	rules := []map[string]any{}
+	for _, rule := range yamlx.LintRules() {
+		rules = append(rules, map[string]any{
+			"id":               rule.ID,
+			"shortDescription": map[string]string{"text": rule.Description},
+		})
+	}
+	results := []map[string]any{}
+	for _, f := range findings {
+		results = append(results, map[string]any{
+			"ruleId":  f.Rule,
+			"level":   string(f.Severity),
+			"message": map[string]string{"text": f.Message},
+			"locations": []map[string]any{{
+				"physicalLocation": map[string]any{
+					"artifactLocation": map[string]string{"uri": f.File},
+					"region":           map[string]int{"startLine": f.Line, "startColumn": f.Column},
+				},
+			}},
+		})
+	}
+	return map[string]any{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []map[string]any{{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "yamlx",
+					"informationUri": "https://github.com/micah5/yamlx",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/micah5/yamlx"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// lsp runs a language server over stdin and stdout.
+func (a *app) lsp(args []string) error {
+	fs := a.newFlagSet("lsp", "")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	s := &languageServer{out: a.stdout, docs: make(map[string]string)}
+	return s.serve(bufio.NewReader(a.stdin))
+}
+
+// languageServer implements the parts of the Language Server Protocol yamlx
+// supports: diagnostics, go to definition, hover, completion and rename.
+type languageServer struct {
+	out  io.Writer
+	docs map[string]string // Text of the open documents, by URI
+}
+
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string    `json:"uri"`
+	Range textRange `json:"range"`
+}
+
+type textEdit struct {
+	Range   textRange `json:"range"`
+	NewText string    `json:"newText"`
+}
+
+type positionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+	NewName  string   `json:"newName"`
+}
+
+// serve reads messages until exit or the end of the input.
+func (s *languageServer) serve(r *bufio.Reader) error {
+	for {
+		msg, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, rpcErr := s.handle(msg)
+		if msg.ID == nil {
+			continue // Notifications have no response
+		}
+		response := rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: rpcErr}
+		if result == nil && rpcErr == nil {
+			response.Result = json.RawMessage("null")
+		}
+		if err := s.send(response); err != nil {
+			return err
+		}
+	}
+}
+
+func readMessage(r *bufio.Reader) (*rpcMessage, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("yamlx: invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	msg := &rpcMessage{}
+	return msg, json.Unmarshal(body, msg)
+}
+
+func (s *languageServer) send(msg rpcMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *languageServer) handle(msg *rpcMessage) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // Full document on every change
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"renameProvider":     true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{"*", "{"}},
+			},
+			"serverInfo": map[string]string{"name": "yamlx"},
+		}, nil
+	case "textDocument/didOpen", "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{-32602, err.Error()}
+		}
+		text := params.TextDocument.Text
+		if n := len(params.ContentChanges); n > 0 {
+			text = params.ContentChanges[n-1].Text
+		}
+		s.docs[params.TextDocument.URI] = text
+		s.publishDiagnostics(params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didClose":
+		var params positionParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+		}
+		return nil, nil
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion", "textDocument/rename":
+		var params positionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{-32602, err.Error()}
+		}
+		text, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, &rpcError{-32602, "unknown document " + params.TextDocument.URI}
+		}
+		lines := strings.Split(text, "\n")
+		if params.Position.Line >= len(lines) {
+			return nil, nil
+		}
+		line := lines[params.Position.Line]
+		pos := cursor{params.Position.Line + 1, byteOffset(line, params.Position.Character)}
+		switch msg.Method {
+		case "textDocument/definition":
+			return definition(params.TextDocument.URI, text, pos), nil
+		case "textDocument/hover":
+			return hover(text, line, pos), nil
+		case "textDocument/completion":
+			return completion(text, line[:pos.offset]), nil
+		default:
+			return rename(params.TextDocument.URI, text, pos, params.NewName)
+		}
+	case "initialized", "$/cancelRequest", "$/setTrace", "shutdown":
+		return nil, nil
+	}
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &rpcError{-32601, "method not found: " + msg.Method}
+}
+
+func (s *languageServer) publishDiagnostics(uri string) {
+	lines := strings.Split(s.docs[uri], "\n")
+	diagnostics := []map[string]any{}
+	for _, d := range yamlx.Lint([]byte(s.docs[uri])) {
+		line := lines[d.Line-1]
+		severity := 2
+		if d.Severity == yamlx.SeverityError {
+			severity = 1
+		}
+		diagnostics = append(diagnostics, map[string]any{
+			"range": textRange{
+				position{d.Line - 1, utf16Column(line, d.Column-1)},
+				position{d.Line - 1, utf16Column(line, len(line))},
+			},
+			"severity": severity,
+			"code":     d.Rule,
+			"source":   "yamlx",
+			"message":  d.Message,
+		})
+	}
+	params, _ := json.Marshal(map[string]any{"uri": uri, "diagnostics": diagnostics})
+	s.send(rpcMessage{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+// cursor is a position in a document as a 1-based line and a byte offset
+// into that line.
+type cursor struct {
+	line   int
+	offset int
+}
+
+// symbolAt returns the anchor symbol under the cursor.
+func symbolAt(text string, pos cursor) (yamlx.Symbol, []yamlx.Symbol, bool) {
+	symbols, err := yamlx.Symbols([]byte(text))
+	if err != nil {
+		return yamlx.Symbol{}, nil, false
+	}
+	for _, symbol := range symbols {
+		start := symbol.Column - 1
+		if symbol.Line == pos.line && pos.offset >= start && pos.offset <= start+len(symbol.Name) {
+			return symbol, symbols, true
+		}
+	}
+	return yamlx.Symbol{}, symbols, false
+}
+
+// definitionOf returns the definition a use of an anchor refers to: the last
+// one before it, or the first in the document if there is none.
+func definitionOf(symbol yamlx.Symbol, symbols []yamlx.Symbol) (yamlx.Symbol, bool) {
+	var found yamlx.Symbol
+	ok := false
+	for _, s := range symbols {
+		if !s.Definition || s.Name != symbol.Name {
+			continue
+		}
+		if !ok || s.Line < symbol.Line || (s.Line == symbol.Line && s.Column <= symbol.Column) {
+			found, ok = s, true
+		}
+		if s.Line > symbol.Line {
+			break
+		}
+	}
+	return found, ok
+}
+
+func definition(uri string, text string, pos cursor) any {
+	symbol, symbols, ok := symbolAt(text, pos)
+	if !ok {
+		return nil
+	}
+	def, ok := definitionOf(symbol, symbols)
+	if !ok {
+		return nil
+	}
+	return location{uri, symbolRange(text, def)}
+}
+
+func hover(text string, line string, pos cursor) any {
+	var contents string
+	if symbol, _, ok := symbolAt(text, pos); ok {
+		if value, err := yamlx.Eval([]byte(text), symbol.Name, yamlx.Options{}); err == nil {
+			contents = fmt.Sprintf("```yaml\n%s: %s\n```", symbol.Name, formatValue(value))
+		}
+	} else if name, ok := functionAt(line, pos.offset); ok {
+		for _, function := range yamlx.Functions() {
+			if function.Name == name {
+				contents = fmt.Sprintf("```\n%s\n```\n%s", function.Signature, function.Description)
+			}
+		}
+	} else if expression, ok := expressionAt(line, pos.offset); ok {
+		if value, err := yamlx.Eval([]byte(text), expression, yamlx.Options{}); err == nil {
+			contents = fmt.Sprintf("```yaml\n%s\n```", formatValue(value))
+		}
+	}
+	if contents == "" {
+		return nil
+	}
+	return map[string]any{"contents": map[string]string{"kind": "markdown", "value": contents}}
+}
+
+// formatValue prints a value on one line, with lists and maps as json.
+func formatValue(value any) string {
+	switch value.(type) {
+	case []any, map[string]any, *yamlx.OrderedMap:
+		if output, err := yamlx.EncodeJSON(value, ""); err == nil {
+			return string(output)
+		}
+	case string:
+		return strconv.Quote(value.(string))
+	}
+	return fmt.Sprint(value)
+}
+
+// functionAt returns the name of the function called at a byte offset of a
+// line, if there is one.
+func functionAt(line string, offset int) (string, bool) {
+	start, end := offset, offset
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWordChar(line[end]) {
+		end++
+	}
+	if start == end || !strings.HasPrefix(strings.TrimLeft(line[end:], " "), "(") {
+		return "", false
+	}
+	return line[start:end], true
+}
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// expressionAt returns the ${} expression around a byte offset of a line.
+func expressionAt(line string, offset int) (string, bool) {
+	start := strings.LastIndex(line[:offset], "${")
+	if start < 0 {
+		return "", false
+	}
+	end := strings.Index(line[start:], "}")
+	if end < 0 || start+end < offset {
+		return "", false
+	}
+	return line[start+2 : start+end], true
+}
+
+func completion(text string, before string) any {
+	items := []map[string]any{}
+	inExpression := strings.LastIndex(before, "${") > strings.LastIndex(before, "}")
+	word := strings.LastIndexFunc(before, func(r rune) bool { return r > 127 || !(isWordChar(byte(r)) || r == '-' || r == '.') })
+	if !inExpression && (word < 0 || before[word] != '*') {
+		return items
+	}
+	symbols, _ := yamlx.Symbols([]byte(text))
+	seen := make(map[string]bool)
+	for _, symbol := range symbols {
+		if symbol.Definition && !seen[symbol.Name] {
+			seen[symbol.Name] = true
+			items = append(items, map[string]any{"label": symbol.Name, "kind": 6, "detail": "anchor"}) // Variable
+		}
+	}
+	if inExpression {
+		for _, function := range yamlx.Functions() {
+			items = append(items, map[string]any{
+				"label":         function.Name,
+				"kind":          3, // Function
+				"detail":        function.Signature,
+				"documentation": function.Description,
+			})
+		}
+	}
+	return items
+}
+
+func rename(uri string, text string, pos cursor, newName string) (any, *rpcError) {
+	valid := newName != ""
+	for i := 0; i < len(newName); i++ {
+		valid = valid && (isWordChar(newName[i]) || newName[i] == '-')
+	}
+	if !valid {
+		return nil, &rpcError{-32602, fmt.Sprintf("invalid anchor name %q", newName)}
+	}
+	symbol, symbols, ok := symbolAt(text, pos)
+	if !ok {
+		return nil, nil
+	}
+	edits := []textEdit{}
+	for _, s := range symbols {
+		if s.Name == symbol.Name {
+			edits = append(edits, textEdit{symbolRange(text, s), newName})
+		}
+	}
+	return map[string]any{"changes": map[string][]textEdit{uri: edits}}, nil
+}
+
+// symbolRange returns the range of a symbol's name.
+func symbolRange(text string, symbol yamlx.Symbol) textRange {
+	line := strings.Split(text, "\n")[symbol.Line-1]
+	start := symbol.Column - 1
+	return textRange{
+		position{symbol.Line - 1, utf16Column(line, start)},
+		position{symbol.Line - 1, utf16Column(line, start+len(symbol.Name))},
+	}
+}
+
+// utf16Column converts a byte offset into a line to the UTF-16 code unit
+// offset the protocol uses. Offsets outside the line are clamped to it.
+func utf16Column(line string, offset int) int {
+	if offset < 0 {
+		offset = 0
+	} else if offset > len(line) {
+		offset = len(line)
+	}
+	return len(utf16.Encode([]rune(line[:offset])))
+}
+
+// byteOffset converts a UTF-16 code unit offset into a line to a byte offset.
+func byteOffset(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
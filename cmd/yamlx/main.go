@@ -0,0 +1,151 @@
+// Command yamlx renders and inspects yamlx templates.
+//
+// Usage:
+//
+//	yamlx render [-o yaml|json|toml] [--indent n] [--strict] [--set key=value]... [--seed n] [--watch] [file]
+//	yamlx eval [--set key=value]... <expression> [file]
+//	yamlx tokens [file]
+//	yamlx lint [--format text|json|sarif] [file]...
+//	yamlx fmt [--check] [-w] [file]...
+//	yamlx lsp
+//	yamlx repl [--set key=value]... <file>
+//	yamlx diff [-o text|json] [--set key=value]... [--seed n] <old> <new>
+//	yamlx explain [--json] [--set key=value]... [file]
+//
+// Files default to stdin, which can also be given as "-".
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// app holds the streams a command reads from and writes to.
+type app struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// commands maps each subcommand to its implementation and a one line summary.
+var commands = map[string]struct {
+	run     func(a *app, args []string) error
+	summary string
+}{
+	"render":  {(*app).render, "evaluate a template and print it as yaml, json or toml"},
+	"eval":    {(*app).eval, "evaluate one expression against a file's anchors"},
+	"tokens":  {(*app).tokens, "print the token tree of a file"},
+	"lint":    {(*app).lint, "check files for problems without rendering them"},
+	"fmt":     {(*app).fmt, "format files canonically"},
+	"lsp":     {(*app).lsp, "run a language server over stdin and stdout"},
+	"repl":    {(*app).repl, "evaluate expressions interactively against a file's anchors"},
+	"diff":    {(*app).diff, "show how the rendered output of two files differs"},
+	"explain": {(*app).explain, "show each step of evaluating a template"},
+}
+
+// errUsage reports bad arguments; the flag package has already explained them.
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs a subcommand and returns the process exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		a.usage()
+		return 2
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "yamlx: unknown command %q\n", args[0])
+		a.usage()
+		return 2
+	}
+	if err := command.run(a, args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		if errors.Is(err, errFindings) {
+			return 1
+		}
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func (a *app) usage() {
+	fmt.Fprintln(a.stderr, "usage: yamlx <command> [arguments]\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(a.stderr, "  %-9s %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlagSet returns a flag set for a subcommand that reports errors to stderr.
+func (a *app) newFlagSet(name string, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "usage: yamlx %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses flags that may come before or after positional arguments,
+// returning the positional ones.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// readInput reads a file, or stdin when the path is empty or "-".
+func (a *app) readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(a.stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// varsFlag collects repeated --set key=value flags.
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	pairs := make([]string, 0, len(v))
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v varsFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	v[key] = value
+	return nil
+}
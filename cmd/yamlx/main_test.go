@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const template = `env: &env dev
+ports: &ports [80, 443]
+host: ${env}.example.com
+`
+
+// runCommand runs the tool with a template on stdin.
+func runCommand(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(template), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRender(t *testing.T) {
+	code, stdout, _ := runCommand("render", "--set", "env=prod")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "env: prod\nports:\n  - 80\n  - 443\nhost: prod.example.com\n", stdout)
+
+	code, stdout, _ = runCommand("render", "-", "-o", "json", "--indent", "0")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `{"env":"dev","ports":[80,443],"host":"dev.example.com"}`+"\n", stdout)
+
+	code, _, stderr := runCommand("render", "-o", "xml")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "yamlx: unknown output format \"xml\"\n", stderr)
+}
+
+func TestEval(t *testing.T) {
+	code, stdout, _ := runCommand("eval", `upper(env) + "!"`)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "DEV!\n", stdout)
+
+	code, stdout, _ = runCommand("eval", "ports")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "[80,443]\n", stdout)
+
+	code, _, _ = runCommand("eval")
+	assert.Equal(t, 2, code)
+}
+
+func TestTokens(t *testing.T) {
+	code, stdout, _ := runCommand("tokens")
+	assert.Equal(t, 0, code)
+	assert.True(t, strings.HasPrefix(stdout, "KEY: env\n ANCHOR: env\n VALUE: dev\n"))
+}
+
+func TestUnknownCommand(t *testing.T) {
+	code, _, stderr := runCommand("frobnicate")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "frobnicate"`)
+}
+
+func TestLint(t *testing.T) {
+	code, stdout, _ := runCommand("lint")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "<stdin>:2:8: warning: anchor \"ports\" is never used (unused-anchor)\n", stdout)
+
+	code, stdout, _ = runCommand("lint", "--format", "json")
+	assert.Equal(t, 1, code)
+	assert.JSONEq(t, `[{"file":"<stdin>","rule":"unused-anchor","severity":"warning","line":2,"column":8,"message":"anchor \"ports\" is never used"}]`, stdout)
+
+	code, stdout, _ = runCommand("lint", "--format", "sarif")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, `"ruleId": "unused-anchor"`)
+	assert.Contains(t, stdout, `"startLine": 2`)
+}
+
+func TestFmt(t *testing.T) {
+	code, stdout, _ := runCommand("fmt")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, template, stdout)
+
+	var out bytes.Buffer
+	code = run([]string{"fmt", "--check"}, strings.NewReader("a:\n    b: ${ x }\n"), &out, &out)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "-\n", out.String())
+}
+
+func TestLSP(t *testing.T) {
+	var input strings.Builder
+	send := func(msg string) {
+		fmt.Fprintf(&input, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	text := `env: &env dev\nunused: &unused 1\nhost: ${env}.example.com\nalias: *env\n`
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	send(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.yaml","text":"` + text + `"}}}`)
+	send(`{"jsonrpc":"2.0","id":2,"method":"textDocument/definition","params":{"textDocument":{"uri":"file:///a.yaml"},"position":{"line":3,"character":9}}}`)
+	send(`{"jsonrpc":"2.0","id":3,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.yaml"},"position":{"line":2,"character":9}}}`)
+	send(`{"jsonrpc":"2.0","id":4,"method":"textDocument/completion","params":{"textDocument":{"uri":"file:///a.yaml"},"position":{"line":3,"character":8}}}`)
+	send(`{"jsonrpc":"2.0","id":5,"method":"textDocument/rename","params":{"textDocument":{"uri":"file:///a.yaml"},"position":{"line":0,"character":7},"newName":"stage"}}`)
+	send(`{"jsonrpc":"2.0","id":6,"method":"shutdown"}`)
+	send(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lsp"}, strings.NewReader(input.String()), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+
+	var messages []map[string]any
+	for _, part := range strings.Split(stdout.String(), "Content-Length: ")[1:] {
+		var msg map[string]any
+		_, body, _ := strings.Cut(part, "\r\n\r\n")
+		assert.Nil(t, json.Unmarshal([]byte(body), &msg))
+		messages = append(messages, msg)
+	}
+	assert.Len(t, messages, 7)
+	assert.Equal(t, true, messages[0]["result"].(map[string]any)["capabilities"].(map[string]any)["renameProvider"])
+
+	diagnostics := messages[1]["params"].(map[string]any)["diagnostics"].([]any)
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, "anchor \"unused\" is never used", diagnostics[0].(map[string]any)["message"])
+
+	assert.JSONEq(t, `{"uri":"file:///a.yaml","range":{"start":{"line":0,"character":6},"end":{"line":0,"character":9}}}`, toJSON(messages[2]["result"]))
+	assert.JSONEq(t, `{"contents":{"kind":"markdown","value":"`+"```yaml\\nenv: \\\"dev\\\"\\n```"+`"}}`, toJSON(messages[3]["result"]))
+	assert.JSONEq(t, `[{"label":"env","kind":6,"detail":"anchor"},{"label":"unused","kind":6,"detail":"anchor"}]`, toJSON(messages[4]["result"]))
+	assert.JSONEq(t, `{"changes":{"file:///a.yaml":[
+		{"range":{"start":{"line":0,"character":6},"end":{"line":0,"character":9}},"newText":"stage"},
+		{"range":{"start":{"line":2,"character":8},"end":{"line":2,"character":11}},"newText":"stage"},
+		{"range":{"start":{"line":3,"character":8},"end":{"line":3,"character":11}},"newText":"stage"}
+	]}}`, toJSON(messages[5]["result"]))
+	assert.Nil(t, messages[6]["result"])
+}
+
+func TestUTF16Column(t *testing.T) {
+	assert.Equal(t, 0, utf16Column("<<: base", -1))
+	assert.Equal(t, 3, utf16Column("é😀x", 6))
+	assert.Equal(t, 4, utf16Column("é😀x", 100))
+}
+
+func toJSON(v any) string {
+	output, _ := json.Marshal(v)
+	return string(output)
+}
+
+func TestRepl(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte("env: &env dev\ndb: &db\n  port: 5432\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	input := "upper(env)\ndb.port + 1\n:anchors\nnope(\n:quit\n"
+	code := run([]string{"repl", file}, strings.NewReader(input), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, `yamlx repl, :help for help
+> "DEV" (string)
+> 5433 (float)
+> db = {"port":5432} (map)
+db.port = 5432 (int)
+env = "dev" (string)
+> error: Unbalanced parenthesis
+> `, stdout.String())
+}
+
+func TestDiff(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "new.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte("env: &env dev\nhost: ${env}.example.org\n"), 0o644))
+
+	code, stdout, _ := runCommand("diff", "-", file)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "- ports: [80,443]\n~ host: \"dev.example.com\" -> \"dev.example.org\"\n", stdout)
+
+	code, stdout, _ = runCommand("diff", "--seed", "42", "-", file)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "- ports: [80,443]\n~ host: \"dev.example.com\" -> \"dev.example.org\"\n", stdout)
+
+	code, stdout, _ = runCommand("diff", "-o", "json", "--set", "env=prod", "-", file)
+	assert.Equal(t, 1, code)
+	assert.JSONEq(t, `[
+		{"path":"ports","kind":"removed","old":[80,443]},
+		{"path":"host","kind":"changed","old":"prod.example.com","new":"prod.example.org"}
+	]`, stdout)
+}
+
+func TestExplain(t *testing.T) {
+	code, stdout, _ := runCommand("explain")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `ANCHOR: &env = "dev" at env (line 1)
+ANCHOR: &ports = [80,443] at ports (line 2)
+EXPRESSION: ${env} with env="dev" = "dev" at host (line 3)
+`, stdout)
+
+	code, stdout, _ = runCommand("explain", "--json")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, `"kind": "expression"`)
+}
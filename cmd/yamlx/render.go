@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"github.com/micah5/yamlx"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// render evaluates a template and prints the result.
+func (a *app) render(args []string) error {
+	fs := a.newFlagSet("render", "[-o yaml|json|toml] [--indent n] [--strict] [--set key=value]... [--seed n] [--watch] [file]")
+	format := fs.String("o", "yaml", "output format: yaml, json or toml")
+	indent := fs.Int("indent", 2, "indentation width; 0 gives compact json")
+	strict := fs.Bool("strict", false, "fail on duplicate keys")
+	seed := fs.Int64("seed", 0, "seed for rand() and the other random functions; 0 derives one from the file")
+	watch := fs.Bool("watch", false, "render again whenever the file changes")
+	vars := varsFlag{}
+	fs.Var(vars, "set", "set a variable, overriding the anchor of the same name (repeatable)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	opts := yamlx.Options{Strict: *strict, Indent: *indent, Vars: vars, Seed: *seed}
+	var renderFunc func([]byte, yamlx.Options) ([]byte, error)
+	switch *format {
+	case "yaml", "yml":
+		renderFunc = yamlx.Render
+	case "json":
+		renderFunc = yamlx.RenderJSON
+	case "toml":
+		renderFunc = yamlx.RenderTOML
+	default:
+		return fmt.Errorf("yamlx: unknown output format %q", *format)
+	}
+	load := func(data []byte) ([]byte, error) { return renderFunc(data, opts) }
+
+	if *watch {
+		if first(positional) == "" || first(positional) == "-" {
+			return fmt.Errorf("yamlx: --watch needs a file")
+		}
+		return a.watch(first(positional), load)
+	}
+	data, err := a.readInput(first(positional))
+	if err != nil {
+		return err
+	}
+	output, err := load(data)
+	if err != nil {
+		return err
+	}
+	_, err = a.stdout.Write(output)
+	return err
+}
+
+// watch prints a file's output and prints it again every time the file
+// changes, until interrupted. Errors are reported and the file watched on.
+func (a *app) watch(path string, load func([]byte) ([]byte, error)) error {
+	w, err := yamlx.WatchFunc(path, 0, load)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err := a.stdout.Write(w.Current()); err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	for {
+		select {
+		case output := <-w.Updates():
+			fmt.Fprintf(a.stdout, "# %s changed at %s\n", path, time.Now().Format(time.TimeOnly))
+			if _, err := a.stdout.Write(output); err != nil {
+				return err
+			}
+		case err := <-w.Errors():
+			fmt.Fprintln(a.stderr, err)
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+// first returns the first argument, or "" if there are none.
+func first(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
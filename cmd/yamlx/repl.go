@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/micah5/yamlx"
+	"sort"
+	"strings"
+)
+
+const replHelp = `Type an expression to evaluate it against the file's anchors, e.g. upper(env).
+Commands:
+  :anchors    list the anchors, including dotted paths into mappings
+  :functions  list the functions expressions can call
+  :reload     read the file again
+  :help       show this help
+  :quit       exit (or send EOF)`
+
+// repl evaluates expressions typed on stdin against the anchors of a file.
+func (a *app) repl(args []string) error {
+	fs := a.newFlagSet("repl", "[--set key=value]... <file>")
+	vars := varsFlag{}
+	fs.Var(vars, "set", "set a variable, overriding the anchor of the same name (repeatable)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] == "-" {
+		// stdin is where the expressions come from
+		fs.Usage()
+		return errUsage
+	}
+
+	opts := yamlx.Options{Vars: vars}
+	data, err := a.readInput(positional[0])
+	if err != nil {
+		return err
+	}
+	if _, err := yamlx.Anchors(data, opts); err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, "yamlx repl, :help for help")
+
+	scanner := bufio.NewScanner(a.stdin)
+	for {
+		fmt.Fprint(a.stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(a.stdout)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+		case ":quit", ":q", ":exit":
+			return nil
+		case ":help":
+			fmt.Fprintln(a.stdout, replHelp)
+		case ":reload":
+			reloaded, err := a.readInput(positional[0])
+			if err == nil {
+				_, err = yamlx.Anchors(reloaded, opts)
+			}
+			if err != nil {
+				fmt.Fprintln(a.stdout, "error:", err)
+				continue
+			}
+			data = reloaded
+		case ":anchors":
+			anchors, err := yamlx.Anchors(data, opts)
+			if err != nil {
+				fmt.Fprintln(a.stdout, "error:", err)
+				continue
+			}
+			names := make([]string, 0, len(anchors))
+			for name := range anchors {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(a.stdout, "%s = %s (%s)\n", name, formatValue(anchors[name]), typeName(anchors[name]))
+			}
+		case ":functions":
+			for _, function := range yamlx.Functions() {
+				fmt.Fprintf(a.stdout, "%-36s %s\n", function.Signature, function.Description)
+			}
+		default:
+			if strings.HasPrefix(line, ":") {
+				fmt.Fprintf(a.stdout, "unknown command %s, :help for help\n", line)
+				continue
+			}
+			result, err := yamlx.Eval(data, line, opts)
+			if err != nil {
+				fmt.Fprintln(a.stdout, "error:", err)
+				continue
+			}
+			fmt.Fprintf(a.stdout, "%s (%s)\n", formatValue(result), typeName(result))
+		}
+	}
+}
+
+// typeName names the type of an evaluated value the way yaml would.
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int, int64:
+		return "int"
+	case float64:
+		return "float"
+	case []any:
+		return "list"
+	case map[string]any, *yamlx.OrderedMap:
+		return "map"
+	}
+	return fmt.Sprintf("%T", value)
+}
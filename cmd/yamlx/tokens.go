@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/micah5/yamlx"
+	"strings"
+)
+
+// tokens prints the token tree of a file.
+func (a *app) tokens(args []string) error {
+	fs := a.newFlagSet("tokens", "[file]")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	data, err := a.readInput(first(positional))
+	if err != nil {
+		return err
+	}
+	tokens, err := yamlx.Tokenize(strings.Split(string(data), "\n"), 0)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		token.Fprint(a.stdout, "")
+	}
+	return nil
+}
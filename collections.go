@@ -0,0 +1,618 @@
+package yamlx
+
+import (
+	"fmt"
+	"github.com/Knetic/govaluate"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scope is what a function needs from the evaluation calling it: the
+// anchors and functions lambdas can use, and checks on the number of items
+// and size of strings it may produce.
+type scope struct {
+	anchors   map[string]any
+	functions map[string]govaluate.ExpressionFunction
+	iterate   func(n int) error
+	checkSize func(n int) error
+}
+
+// scopedFunction is a function that needs the evaluation calling it.
+type scopedFunction func(s scope, args ...any) (any, error)
+
+// scopedFunctions are bound to each evaluation, like randomFunctions.
+var scopedFunctions = map[string]scopedFunction{
+	"map":    mapItems,
+	"filter": filterItems,
+	"range":  rangeItems,
+	"pad":    pad,
+	"indent": indent,
+}
+
+func init() {
+	// Outside of an evaluation lambdas see no anchors. These are added here
+	// since they refer to functions.
+	for name, function := range scopedFunctions {
+		function := function
+		functions[name] = func(args ...any) (any, error) {
+			return function(scope{anchors: map[string]any{}, functions: functions}, args...)
+		}
+	}
+}
+
+// lambda is a function written as a string, like "x => x * 2".
+type lambda struct {
+	variable string
+	body     string
+}
+
+func parseLambda(name string, value any) (lambda, error) {
+	s, _ := value.(string)
+	variable, body, found := strings.Cut(s, "=>")
+	variable = strings.TrimSpace(variable)
+	if !found || !isIdentifier(variable) {
+		return lambda{}, fmt.Errorf("%s function requires a lambda like \"x => x * 2\"", name)
+	}
+	return lambda{variable, strings.TrimSpace(body)}, nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || !isIdentifierStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply evaluates the body of a lambda for each item of a list.
+func (l lambda) apply(s scope, items []any, each func(item, result any) error) error {
+	if s.iterate != nil {
+		if err := s.iterate(len(items)); err != nil {
+			return err
+		}
+	}
+	anchors := make(map[string]any, len(s.anchors)+1)
+	for name, value := range s.anchors {
+		anchors[name] = value
+	}
+	var flattened map[string]any
+	for _, item := range items {
+		for name := range flattened {
+			delete(anchors, name)
+		}
+		anchors[l.variable] = item
+		flattened = nil
+		if m, ok := item.(map[string]any); ok {
+			flattened = createAnchorMap(m, l.variable)
+			for name, value := range flattened {
+				anchors[name] = value
+			}
+		}
+		result, err := evaluateWithFunctions(l.body, anchors, s.functions)
+		if err != nil {
+			return err
+		}
+		if err := each(item, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listAndLambda reads the arguments of map and filter.
+func listAndLambda(name string, args []any) ([]any, lambda, error) {
+	if len(args) != 2 {
+		return nil, lambda{}, fmt.Errorf("%s function requires a slice and a lambda", name)
+	}
+	items, ok := listItems(args[0])
+	if !ok {
+		return nil, lambda{}, fmt.Errorf("%s function requires a slice", name)
+	}
+	l, err := parseLambda(name, args[1])
+	return items, l, err
+}
+
+func mapItems(s scope, args ...any) (any, error) {
+	items, l, err := listAndLambda("map", args)
+	if err != nil {
+		return nil, err
+	}
+	result := make(List, 0, len(items))
+	err = l.apply(s, items, func(_, value any) error {
+		result = append(result, value)
+		return nil
+	})
+	return result, err
+}
+
+func filterItems(s scope, args ...any) (any, error) {
+	items, l, err := listAndLambda("filter", args)
+	if err != nil {
+		return nil, err
+	}
+	result := List{}
+	err = l.apply(s, items, func(item, keep any) error {
+		b, ok := keep.(bool)
+		if !ok {
+			return fmt.Errorf("filter function requires a lambda that returns a boolean")
+		}
+		if b {
+			result = append(result, item)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func rangeItems(s scope, args ...any) (any, error) {
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		f, ok := arg.(float64)
+		if !ok || f != float64(int64(f)) {
+			return nil, fmt.Errorf("range function requires whole numbers")
+		}
+		bounds[i] = int64(f)
+	}
+	var start, end, step int64 = 0, 0, 1
+	switch len(bounds) {
+	case 1:
+		end = bounds[0]
+	case 2:
+		start, end = bounds[0], bounds[1]
+	case 3:
+		start, end, step = bounds[0], bounds[1], bounds[2]
+	default:
+		return nil, fmt.Errorf("range function requires 1 to 3 arguments")
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("range function requires a step other than 0")
+	}
+	// Counted in uint64, as the distance between two int64s may not fit
+	var distance, stride uint64
+	if step > 0 && end > start {
+		distance, stride = uint64(end)-uint64(start), uint64(step)
+	} else if step < 0 && end < start {
+		distance, stride = uint64(start)-uint64(end), uint64(-step)
+	}
+	count := uint64(0)
+	if distance > 0 {
+		count = (distance-1)/stride + 1
+	}
+	if count > maxLength {
+		return nil, fmt.Errorf("range function requires at most %d items", maxLength)
+	}
+	if s.iterate != nil {
+		if err := s.iterate(int(count)); err != nil {
+			return nil, err
+		}
+	}
+	result := make(List, count)
+	for i := range result {
+		result[i] = start + int64(i)*step
+	}
+	return result, nil
+}
+
+// listArg returns the list argument of a function that takes one.
+func listArg(name string, args []any) ([]any, error) {
+	if len(args) == 1 {
+		if items, ok := listItems(args[0]); ok {
+			return items, nil
+		}
+	}
+	return nil, fmt.Errorf("%s function requires a slice", name)
+}
+
+// mapArg returns the map argument of a function that takes one, with its
+// keys sorted.
+func mapArg(name string, args []any) (map[string]any, []string, error) {
+	if len(args) == 1 {
+		if m, ok := args[0].(map[string]any); ok {
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return m, keys, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%s function requires a map", name)
+}
+
+func keys(args ...any) (any, error) {
+	_, keys, err := mapArg("keys", args)
+	if err != nil {
+		return nil, err
+	}
+	result := make(List, len(keys))
+	for i, k := range keys {
+		result[i] = k
+	}
+	return result, nil
+}
+
+func values(args ...any) (any, error) {
+	m, keys, err := mapArg("values", args)
+	if err != nil {
+		return nil, err
+	}
+	result := make(List, len(keys))
+	for i, k := range keys {
+		result[i] = m[k]
+	}
+	return result, nil
+}
+
+// compareValues orders two numbers or two strings.
+func compareValues(a, b any) (int, error) {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %v and %v", a, b)
+}
+
+// sortItems sorts a copy of items by the values key returns for them.
+func sortItems(name string, items []any, key func(any) any) (List, error) {
+	result := append(List(nil), items...)
+	var err error
+	sort.SliceStable(result, func(i, j int) bool {
+		c, cerr := compareValues(key(result[i]), key(result[j]))
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("%s function requires numbers or strings: %w", name, cerr)
+		}
+		return c < 0
+	})
+	return result, err
+}
+
+func sortList(args ...any) (any, error) {
+	return sortItems("sort", spread(args), func(v any) any { return v })
+}
+
+func sortby(args ...any) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("sortby function requires a slice and a key")
+	}
+	items, ok := listItems(args[0])
+	key, isString := args[1].(string)
+	if !ok || !isString {
+		return nil, fmt.Errorf("sortby function requires a slice and a key")
+	}
+	return sortItems("sortby", items, func(v any) any {
+		if m, ok := v.(map[string]any); ok {
+			return m[key]
+		}
+		return nil
+	})
+}
+
+func uniq(args ...any) (any, error) {
+	result := List{}
+	for _, item := range spread(args) {
+		seen := false
+		for _, v := range result {
+			if equalValues(v, item) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func reverse(args ...any) (any, error) {
+	items := spread(args)
+	result := make(List, len(items))
+	for i, item := range items {
+		result[len(items)-1-i] = item
+	}
+	return result, nil
+}
+
+func flatten(args ...any) (any, error) {
+	result := List{}
+	var walk func(items []any)
+	walk = func(items []any) {
+		for _, item := range items {
+			if nested, ok := listItems(item); ok {
+				walk(nested)
+			} else {
+				result = append(result, item)
+			}
+		}
+	}
+	walk(spread(args))
+	return result, nil
+}
+
+func concat(args ...any) (any, error) {
+	result := List{}
+	for _, arg := range args {
+		if items, ok := listItems(arg); ok {
+			result = append(result, items...)
+		} else {
+			result = append(result, arg)
+		}
+	}
+	return result, nil
+}
+
+func slice(args ...any) (any, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("slice function requires a slice, a start and an optional end")
+	}
+	items, ok := listItems(args[0])
+	if !ok {
+		return nil, fmt.Errorf("slice function requires a slice")
+	}
+	bounds := []int{0, len(items)}
+	for i, arg := range args[1:] {
+		f, ok := arg.(float64)
+		if !ok {
+			return nil, fmt.Errorf("slice function requires integer bounds")
+		}
+		// Negative bounds count back from the end
+		bound := int(f)
+		if bound < 0 {
+			bound += len(items)
+		}
+		if bound < 0 {
+			bound = 0
+		} else if bound > len(items) {
+			bound = len(items)
+		}
+		bounds[i] = bound
+	}
+	if bounds[1] < bounds[0] {
+		return List{}, nil
+	}
+	return append(List(nil), items[bounds[0]:bounds[1]]...), nil
+}
+
+func first(args ...any) (any, error) {
+	items, err := listArg("first", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("first function requires a non-empty slice")
+	}
+	return items[0], nil
+}
+
+func last(args ...any) (any, error) {
+	items, err := listArg("last", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("last function requires a non-empty slice")
+	}
+	return items[len(items)-1], nil
+}
+
+func zip(args ...any) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("zip function requires at least 2 slices")
+	}
+	lists := make([][]any, len(args))
+	length := -1
+	for i, arg := range args {
+		items, ok := listItems(arg)
+		if !ok {
+			return nil, fmt.Errorf("zip function requires slices")
+		}
+		lists[i] = items
+		if length < 0 || len(items) < length {
+			length = len(items)
+		}
+	}
+	result := make(List, length)
+	for i := range result {
+		tuple := make(List, len(lists))
+		for j, items := range lists {
+			tuple[j] = items[i]
+		}
+		result[i] = tuple
+	}
+	return result, nil
+}
+
+// lookupPath follows a dotted path like "db.hosts.0" through nested maps and
+// lists.
+func lookupPath(value any, path string) (any, bool) {
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		default:
+			items, ok := listItems(v)
+			if !ok {
+				return nil, false
+			}
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(items) {
+				return nil, false
+			}
+			value = items[i]
+		}
+	}
+	return value, true
+}
+
+// mapAndPath reads the map and path arguments of get and has.
+func mapAndPath(name string, args []any) (map[string]any, string, error) {
+	if len(args) >= 2 {
+		m, ok := args[0].(map[string]any)
+		path, isString := args[1].(string)
+		if ok && isString {
+			return m, path, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%s function requires a map and a path", name)
+}
+
+func get(args ...any) (any, error) {
+	if len(args) > 3 {
+		return nil, fmt.Errorf("get function requires a map, a path and an optional default")
+	}
+	m, path, err := mapAndPath("get", args)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := lookupPath(m, path); ok {
+		return value, nil
+	}
+	if len(args) == 3 {
+		return args[2], nil
+	}
+	return nil, fmt.Errorf("get function found no value at %q", path)
+}
+
+func has(args ...any) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("has function requires a map and a path")
+	}
+	m, path, err := mapAndPath("has", args)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := lookupPath(m, path)
+	return ok, nil
+}
+
+// mergeMaps merges src into a copy of dst, merging nested maps too.
+func mergeMaps(dst, src map[string]any) map[string]any {
+	result := make(map[string]any, len(dst)+len(src))
+	for k, v := range dst {
+		result[k] = v
+	}
+	for k, v := range src {
+		if srcMap, ok := v.(map[string]any); ok {
+			if dstMap, ok := result[k].(map[string]any); ok {
+				v = mergeMaps(dstMap, srcMap)
+			}
+		}
+		result[k] = v
+	}
+	return result
+}
+
+func merge(args ...any) (any, error) {
+	result := map[string]any{}
+	for _, arg := range args {
+		m, ok := arg.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("merge function requires maps")
+		}
+		result = mergeMaps(result, m)
+	}
+	return result, nil
+}
+
+// mapAndKeys reads the arguments of pick and omit: a map, then keys given
+// separately or as a list.
+func mapAndKeys(name string, args []any) (map[string]any, map[string]bool, error) {
+	if len(args) < 2 {
+		return nil, nil, fmt.Errorf("%s function requires a map and keys", name)
+	}
+	m, ok := args[0].(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s function requires a map", name)
+	}
+	keys := make(map[string]bool)
+	for _, arg := range args[1:] {
+		items, ok := listItems(arg)
+		if !ok {
+			items = []any{arg}
+		}
+		for _, item := range items {
+			key, ok := item.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s function requires string keys", name)
+			}
+			keys[key] = true
+		}
+	}
+	return m, keys, nil
+}
+
+func pick(args ...any) (any, error) {
+	m, keys, err := mapAndKeys("pick", args)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]any)
+	for k, v := range m {
+		if keys[k] {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+func omit(args ...any) (any, error) {
+	m, keys, err := mapAndKeys("omit", args)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]any)
+	for k, v := range m {
+		if !keys[k] {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+func dict(args ...any) (any, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("dict function requires pairs of keys and values")
+	}
+	result := make(map[string]any, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict function requires string keys")
+		}
+		result[key] = args[i+1]
+	}
+	return result, nil
+}
+
+func items(args ...any) (any, error) {
+	m, keys, err := mapArg("items", args)
+	if err != nil {
+		return nil, err
+	}
+	result := make(List, len(keys))
+	for i, k := range keys {
+		result[i] = map[string]any{"key": k, "value": m[k]}
+	}
+	return result, nil
+}
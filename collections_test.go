@@ -0,0 +1,146 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCollectionFunctions(t *testing.T) {
+	yamlContent := `
+numbers: &numbers [3, 1, 2, 3]
+names: &names [carol, alice, bob]
+ports: &ports
+  http: 80
+  https: 443
+servers: &servers
+  - name: web
+    port: 8080
+  - name: db
+    port: 5432
+keys: ${keys(ports)}
+values: ${values(ports)}
+sorted: ${sort(numbers)}
+names: ${join(",", sort(names))}
+sortby: ${map(sortby(servers, "port"), "s => s.name")}
+uniq: ${uniq(numbers)}
+reverse: ${reverse(names)}
+flatten: ${flatten([[1, 2], [3, [4]]])}
+concat: ${concat(names, ["dave"])}
+slice: ${slice(numbers, 1, 3)}
+tail: ${slice(numbers, -2)}
+first: ${first(names)}
+last: ${last(names)}
+range: ${range(3)}
+steps: ${range(10, 0, -4)}
+zip: ${zip(names, numbers)}
+doubled: ${map(numbers, "n => n * 2")}
+big: ${filter(servers, "s => s.port > 6000")}
+count: ${len(filter(numbers, "n => n == 3"))}
+inline: "sorted: ${sort(numbers)}"
+`
+	tokens, _ := Tokenize(strings.Split(yamlContent, "\n"), 0)
+	result, err := Parse(tokens)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []any{"http", "https"}, result["keys"])
+	assert.Equal(t, []any{int64(80), int64(443)}, result["values"])
+	assert.Equal(t, []any{int64(1), int64(2), int64(3), int64(3)}, result["sorted"])
+	assert.Equal(t, "alice,bob,carol", result["names"])
+	assert.Equal(t, []any{"db", "web"}, result["sortby"])
+	assert.Equal(t, []any{int64(3), int64(1), int64(2)}, result["uniq"])
+	assert.Equal(t, []any{"bob", "alice", "carol"}, result["reverse"])
+	assert.Equal(t, []any{float64(1), float64(2), float64(3), float64(4)}, result["flatten"])
+	assert.Equal(t, []any{"carol", "alice", "bob", "dave"}, result["concat"])
+	assert.Equal(t, []any{int64(1), int64(2)}, result["slice"])
+	assert.Equal(t, []any{int64(2), int64(3)}, result["tail"])
+	assert.Equal(t, "carol", result["first"])
+	assert.Equal(t, "bob", result["last"])
+	assert.Equal(t, []any{int64(0), int64(1), int64(2)}, result["range"])
+	assert.Equal(t, []any{int64(10), int64(6), int64(2)}, result["steps"])
+	assert.Equal(t, []any{[]any{"carol", int64(3)}, []any{"alice", int64(1)}, []any{"bob", int64(2)}}, result["zip"])
+	assert.Equal(t, []any{float64(6), float64(2), float64(4), float64(6)}, result["doubled"])
+	assert.Equal(t, []any{map[string]any{"name": "web", "port": int64(8080)}}, result["big"])
+	assert.Equal(t, int64(2), result["count"])
+	assert.Equal(t, "sorted: [1 2 3 3]", result["inline"])
+}
+
+func TestCollectionFunctionErrors(t *testing.T) {
+	for _, expression := range []string{
+		`sort(["a", 1])`,
+		`first([])`,
+		`map([1], "not a lambda")`,
+		`filter([1], "x => x + 1")`,
+		`range(1, 2, 0)`,
+		`keys([1])`,
+		`zip([1])`,
+	} {
+		_, err := Eval([]byte("a: 1"), expression, Options{})
+		assert.Error(t, err, expression)
+	}
+
+	_, err := Eval([]byte("a: 1"), "range(100)", Options{Limits: Limits{MaxIterations: 10}})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestRangeSize(t *testing.T) {
+	for _, expression := range []string{"range(9000000000000000000)", "range(-9000000000000000000, 9000000000000000000)"} {
+		_, err := RenderJSON([]byte("a: ${"+expression+"}"), Options{})
+		assert.ErrorContains(t, err, "range function requires at most", expression)
+	}
+
+	out, err := RenderJSON([]byte("a: ${range(-9000000000000000000, 9000000000000000000, 3000000000000000000)}"), Options{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":[-9000000000000000000,-6000000000000000000,-3000000000000000000,0,3000000000000000000,6000000000000000000]}`, string(out))
+}
+
+func TestMapFunctions(t *testing.T) {
+	yamlContent := `
+db: &db
+  host: localhost
+  port: 5432
+  replicas: [a, b]
+  auth:
+    user: admin
+overrides: &overrides
+  port: 6432
+  auth:
+    password: secret
+host: ${get(db, "host")}
+user: ${get(db, "auth.user")}
+replica: ${get(db, "replicas.1")}
+missing: ${get(db, "auth.token", "none")}
+has: ${has(db, "auth.user")}
+hasNot: ${has(db, "timeout")}
+merged: ${merge(db, overrides)}
+picked: ${pick(db, "host", "port")}
+omitted: ${omit(db, ["replicas", "auth"])}
+dict: ${dict("name", "web", "port", 80)}
+items: ${map(items(overrides), "i => i.key")}
+`
+	tokens, _ := Tokenize(strings.Split(yamlContent, "\n"), 0)
+	result, err := Parse(tokens)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "localhost", result["host"])
+	assert.Equal(t, "admin", result["user"])
+	assert.Equal(t, "b", result["replica"])
+	assert.Equal(t, "none", result["missing"])
+	assert.Equal(t, true, result["has"])
+	assert.Equal(t, false, result["hasNot"])
+	assert.Equal(t, map[string]any{
+		"host":     "localhost",
+		"port":     int64(6432),
+		"replicas": []any{"a", "b"},
+		"auth":     map[string]any{"user": "admin", "password": "secret"},
+	}, result["merged"])
+	assert.Equal(t, map[string]any{"host": "localhost", "port": int64(5432)}, result["picked"])
+	assert.Equal(t, map[string]any{"host": "localhost", "port": int64(5432)}, result["omitted"])
+	assert.Equal(t, map[string]any{"name": "web", "port": float64(80)}, result["dict"])
+	assert.Equal(t, []any{"auth", "port"}, result["items"])
+
+	for _, expression := range []string{`get(db, "nope")`, `dict("a")`, `merge(db, 1)`, `pick(db)`} {
+		_, err := Eval([]byte("db: &db\n  a: 1"), expression, Options{})
+		assert.Error(t, err, expression)
+	}
+}
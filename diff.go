@@ -0,0 +1,113 @@
+package yamlx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind is the way a value differs between two renders.
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change is a single difference found by Diff.
+type Change struct {
+	Path string     `json:"path"` // e.g. servers[1].host
+	Kind ChangeKind `json:"kind"`
+	Old  any        `json:"old,omitempty"` // Unset for Added
+	New  any        `json:"new,omitempty"` // Unset for Removed
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, inlineValue(c.New))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Path, inlineValue(c.Old))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, inlineValue(c.Old), inlineValue(c.New))
+}
+
+// inlineValue writes a value on one line as JSON.
+func inlineValue(value any) string {
+	output, err := EncodeJSON(value, "")
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(output)
+}
+
+// Diff evaluates two yamlx templates with the same options and returns how
+// their output differs, in the order keys appear in the old output followed
+// by keys only the new one has. Both sides draw random values from the same
+// seed: opts.Seed, or one derived from the old template, so editing a line
+// doesn't change every random value.
+func Diff(oldData, newData []byte, opts Options) ([]Change, error) {
+	if opts.Seed == 0 {
+		tokens, err := Tokenize(strings.Split(string(oldData), "\n"), 0)
+		if err != nil {
+			return nil, fmt.Errorf("yamlx: old: %w", err)
+		}
+		opts.Seed = seedTokens(tokens)
+	}
+	oldResult, _, err := evaluate(oldData, opts)
+	if err != nil {
+		return nil, fmt.Errorf("yamlx: old: %w", err)
+	}
+	newResult, _, err := evaluate(newData, opts)
+	if err != nil {
+		return nil, fmt.Errorf("yamlx: new: %w", err)
+	}
+	return DiffValues(oldResult, newResult), nil
+}
+
+// DiffValues compares two values from Parse or ParseOrdered. Mappings are
+// compared key by key and lists item by item; anything else that differs,
+// including a change of type, is reported as a whole.
+func DiffValues(oldValue, newValue any) []Change {
+	var changes []Change
+	diffValues(&changes, "", oldValue, newValue)
+	return changes
+}
+
+func diffValues(changes *[]Change, path string, oldValue, newValue any) {
+	oldMap, newMap := toOrderedMap(oldValue), toOrderedMap(newValue)
+	oldList, oldIsList := oldValue.([]any)
+	newList, newIsList := newValue.([]any)
+	switch {
+	case oldMap != nil && newMap != nil:
+		for _, k := range oldMap.keys {
+			if newValue, ok := newMap.values[k]; ok {
+				diffValues(changes, joinPath(path, k), oldMap.values[k], newValue)
+			} else {
+				*changes = append(*changes, Change{Path: joinPath(path, k), Kind: Removed, Old: oldMap.values[k]})
+			}
+		}
+		for _, k := range newMap.keys {
+			if _, ok := oldMap.values[k]; !ok {
+				*changes = append(*changes, Change{Path: joinPath(path, k), Kind: Added, New: newMap.values[k]})
+			}
+		}
+	case oldIsList && newIsList:
+		for i := 0; i < len(oldList) || i < len(newList); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(newList):
+				*changes = append(*changes, Change{Path: itemPath, Kind: Removed, Old: oldList[i]})
+			case i >= len(oldList):
+				*changes = append(*changes, Change{Path: itemPath, Kind: Added, New: newList[i]})
+			default:
+				diffValues(changes, itemPath, oldList[i], newList[i])
+			}
+		}
+	default:
+		if !reflect.DeepEqual(plainValue(oldValue), plainValue(newValue)) {
+			*changes = append(*changes, Change{Path: path, Kind: Changed, Old: oldValue, New: newValue})
+		}
+	}
+}
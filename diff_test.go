@@ -0,0 +1,59 @@
+package yamlx
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	oldContent := `
+envs: &envs [dev, prod]
+region: eu
+servers:
+  !for env in *envs:
+    - host: ${env}.example.com
+      port: 22
+`
+	newContent := `
+envs: &envs [dev, prod, qa]
+servers:
+  !for env in *envs:
+    - host: ${env}.example.com
+      port: ${env == "prod" ? 2222 : 22}
+replicas: 3
+`
+	changes, err := Diff([]byte(oldContent), []byte(newContent), Options{})
+	assert.Nil(t, err)
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	assert.Equal(t, []string{
+		`+ envs[2]: "qa"`,
+		`- region: "eu"`,
+		`~ servers[1].port: 22 -> 2222`,
+		`+ servers[2]: {"host":"qa.example.com","port":22}`,
+		`+ replicas: 3`,
+	}, lines)
+
+	output, err := json.Marshal(changes[3])
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"path":"servers[2]","kind":"added","new":{"host":"qa.example.com","port":22}}`, string(output))
+
+	changes, err = Diff([]byte(oldContent), []byte(oldContent), Options{})
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiffRandomValues(t *testing.T) {
+	oldContent := "token: ${randstr(16)}\nport: ${randint(1000, 9999)}\nname: web\n"
+	newContent := "token: ${randstr(16)}\nport: ${randint(1000, 9999)}\nname: api\n"
+	changes, err := Diff([]byte(oldContent), []byte(newContent), Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, []Change{{Path: "name", Kind: Changed, Old: "web", New: "api"}}, changes)
+
+	changes, err = Diff([]byte(oldContent), []byte(newContent), Options{Seed: 7})
+	assert.Nil(t, err)
+	assert.Len(t, changes, 1)
+}
@@ -0,0 +1,370 @@
+package yamlx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Document is the syntax tree of a yamlx file. Unlike Tokenize it keeps
+// everything needed to write the file back out unchanged: template
+// constructs, comments, blank lines, key order, indentation and quoting.
+// Nodes can be edited in place and the document re-emitted with Bytes, which
+// reproduces untouched lines exactly.
+type Document struct {
+	Nodes    []*Node  // Top level nodes
+	Trailing []string // Comment and blank lines after the last node, verbatim
+}
+
+// Node is a single line of a yamlx document, along with the lines nested
+// under it.
+type Node struct {
+	Type     Type     // KEY, LIST_ITEM, LOOP or MERGE_KEY
+	Key      string   // The key, or the loop variables for a LOOP, e.g. "idx, name"
+	Value    string   // The raw value, e.g. `${name}.com` or `"quoted"`, or the range of a LOOP
+	Anchor   string   // Anchor defined on the line, without the &
+	Alias    string   // Alias used as the value, without the *
+	Comment  string   // Trailing comment, including the #
+	Head     []string // Comment and blank lines before the node, verbatim
+	Children []*Node
+	Indent   int // Number of leading spaces
+	Line     int // Source line (1-based), or 0 for nodes added after parsing
+
+	raw  string     // The original line
+	orig nodeFields // The fields as parsed, to tell whether the node was edited
+}
+
+// nodeFields are the parts of a node that are written to its line.
+type nodeFields struct {
+	Type    Type
+	Key     string
+	Value   string
+	Anchor  string
+	Alias   string
+	Comment string
+	Indent  int
+}
+
+func (n *Node) fields() nodeFields {
+	return nodeFields{n.Type, n.Key, n.Value, n.Anchor, n.Alias, n.Comment, n.Indent}
+}
+
+// ParseDocument parses yamlx source into a Document.
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{}
+	var stack []*Node
+	var pending []string
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			pending = append(pending, line)
+			continue
+		}
+
+		node, err := parseNodeLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		node.Line = i + 1
+		node.Head = pending
+		pending = nil
+
+		for len(stack) > 0 && stack[len(stack)-1].Indent >= node.Indent {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			doc.Nodes = append(doc.Nodes, node)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack, node)
+	}
+	doc.Trailing = pending
+	return doc, nil
+}
+
+// parseNodeLine parses a single line of source into a node. Tokenize reads
+// lines with it too, so a document accepts exactly the lines a template
+// does, and splits them in the same places.
+func parseNodeLine(line string) (*Node, error) {
+	node := &Node{raw: line, Indent: countLeadingSpaces(line)}
+	content, comment := splitComment(strings.TrimSpace(line))
+	node.Comment = comment
+
+	switch {
+	case strings.Contains(content, "!for"):
+		// !for <variables> in <range>:
+		start := strings.Index(content, "!for") + len("!for")
+		end := strings.Index(content, ":")
+		if end < start {
+			return nil, fmt.Errorf("invalid for loop: %s", content)
+		}
+		parts := strings.Split(strings.TrimSpace(content[start:end]), " in ")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid for loop: %s", content)
+		}
+		node.Type = LOOP
+		node.Key = strings.TrimSpace(parts[0])
+		node.Value = strings.TrimSpace(parts[1])
+	case strings.HasPrefix(content, "- "):
+		node.Type = LIST_ITEM
+		item, value, ok := strings.Cut(content[2:], ":")
+		if !ok {
+			node.Value = strings.TrimSpace(item)
+			break
+		}
+		node.Key = strings.TrimSpace(item)
+		node.setValue(strings.TrimSpace(value))
+	case strings.HasPrefix(content, "<<: *"):
+		node.Type = MERGE_KEY
+		node.Alias = strings.TrimSpace(strings.TrimPrefix(content, "<<: *"))
+	case strings.Contains(content, ": &"):
+		key, value, _ := strings.Cut(content, ": &")
+		node.Type = KEY
+		node.Key = strings.TrimSpace(key)
+		node.setValue("&" + strings.TrimSpace(value))
+	case strings.Contains(content, ": *"):
+		key, alias, _ := strings.Cut(content, ": *")
+		node.Type = KEY
+		node.Key = strings.TrimSpace(key)
+		node.Alias = strings.TrimSpace(alias)
+	case strings.Contains(content, ":"):
+		key, value, _ := strings.Cut(content, ":")
+		node.Type = KEY
+		node.Key = strings.TrimSpace(key)
+		node.Value = strings.TrimSpace(value)
+	default:
+		return nil, fmt.Errorf("invalid line: %s", content)
+	}
+	node.orig = node.fields()
+	return node, nil
+}
+
+// valueText joins the anchor, alias and value of a node back into the text
+// after its colon.
+func (n *Node) valueText() string {
+	value := n.Value
+	if n.Alias != "" {
+		value = "*" + n.Alias
+	}
+	if n.Anchor != "" {
+		value = strings.TrimSpace("&" + n.Anchor + " " + value)
+	}
+	return value
+}
+
+// setValue splits the value part of a line into its anchor, alias and value.
+func (n *Node) setValue(value string) {
+	if strings.HasPrefix(value, "&") {
+		anchor, rest, _ := strings.Cut(value[1:], " ")
+		n.Anchor = anchor
+		value = strings.TrimSpace(rest)
+	}
+	if strings.HasPrefix(value, "*") {
+		n.Alias = value[1:]
+		return
+	}
+	n.Value = value
+}
+
+// splitComment separates a trailing comment from a line. The tokenizer uses it
+// too, so for every document a # only starts a comment at the start of a line
+// or after whitespace, and never inside quotes, expressions or flow lists.
+func splitComment(line string) (string, string) {
+	for i := range line {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') && !insideLiteral(line, i) {
+			return strings.TrimSpace(line[:i]), line[i:]
+		}
+	}
+	return line, ""
+}
+
+// insideLiteral reports whether position i of s is inside quotes, an
+// expression or a flow list.
+func insideLiteral(s string, i int) bool {
+	var quote byte
+	depth := 0
+	for j := 0; j < i; j++ {
+		switch ch := s[j]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case (ch == '"' || ch == '\'') && (j == 0 || strings.IndexByte(" ,[(:", s[j-1]) >= 0):
+			quote = ch
+		case ch == '{' || ch == '[':
+			depth++
+		case ch == '}' || ch == ']':
+			depth--
+		}
+	}
+	return quote != 0 || depth > 0
+}
+
+// Bytes re-emits the document. Lines whose node hasn't been edited are
+// written exactly as they were parsed.
+func (d *Document) Bytes() []byte {
+	var lines []string
+	lines = appendNodeLines(lines, d.Nodes, -1)
+	lines = append(lines, d.Trailing...)
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func (d *Document) String() string {
+	return string(d.Bytes())
+}
+
+// appendNodeLines appends the lines of sibling nodes and their children.
+// Nodes added after parsing are indented like the sibling before them, or
+// one level deeper than their parent.
+func appendNodeLines(lines []string, nodes []*Node, parentIndent int) []string {
+	indent := parentIndent + 2
+	if parentIndent < 0 {
+		indent = 0
+	}
+	for _, n := range nodes {
+		if n.raw == "" && n.Indent <= parentIndent {
+			n.Indent = indent
+		}
+		indent = n.Indent
+		lines = append(lines, n.Head...)
+		if n.raw != "" && n.fields() == n.orig {
+			lines = append(lines, n.raw)
+		} else {
+			lines = append(lines, n.Format())
+		}
+		lines = appendNodeLines(lines, n.Children, n.Indent)
+	}
+	return lines
+}
+
+// Format renders the node's own line from its fields.
+func (n *Node) Format() string {
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", n.Indent))
+	switch n.Type {
+	case LOOP:
+		fmt.Fprintf(&b, "!for %s in %s:", n.Key, n.Value)
+	case MERGE_KEY:
+		fmt.Fprintf(&b, "<<: *%s", n.Alias)
+	case LIST_ITEM:
+		b.WriteString("-")
+		if n.Key != "" {
+			b.WriteString(" " + n.Key + ":")
+			b.WriteString(n.formatValue())
+		} else if n.Value != "" {
+			b.WriteString(" " + n.Value)
+		}
+	default:
+		b.WriteString(n.Key + ":")
+		b.WriteString(n.formatValue())
+	}
+	if n.Comment != "" {
+		b.WriteString(" " + n.Comment)
+	}
+	return b.String()
+}
+
+// formatValue renders the part of a line after the colon.
+func (n *Node) formatValue() string {
+	var b strings.Builder
+	if n.Anchor != "" {
+		b.WriteString(" &" + n.Anchor)
+	}
+	if n.Alias != "" {
+		b.WriteString(" *" + n.Alias)
+	} else if n.Value != "" {
+		b.WriteString(" " + n.Value)
+	}
+	return b.String()
+}
+
+// Tokens tokenizes the document as it currently stands, so an edited
+// document can be evaluated with Parse.
+func (d *Document) Tokens() ([]*Token, error) {
+	return Tokenize(strings.Split(string(d.Bytes()), "\n"), 0)
+}
+
+// Find returns the node at a path like "servers[0].host", where list indexes
+// count the list items under a node. It returns nil if there is no such node.
+func (d *Document) Find(path string) *Node {
+	nodes := d.Nodes
+	var current *Node
+	for _, segment := range splitPath(path) {
+		current = nil
+		if index, err := strconv.Atoi(segment); err == nil {
+			items := 0
+			for _, node := range nodes {
+				if node.Type == LIST_ITEM {
+					if items == index {
+						current = node
+						break
+					}
+					items++
+				}
+			}
+		} else {
+			for _, node := range nodes {
+				if node.Key == segment && (node.Type == KEY || node.Type == LIST_ITEM) {
+					current = node
+					break
+				}
+			}
+		}
+		if current == nil {
+			return nil
+		}
+		if current.Type == LIST_ITEM && current.Key != "" {
+			// The keys of "- key: value" items live alongside the item's own key
+			nodes = append([]*Node{current}, current.Children...)
+		} else {
+			nodes = current.Children
+		}
+	}
+	return current
+}
+
+// Remove deletes the node at a path, returning false if it doesn't exist.
+func (d *Document) Remove(path string) bool {
+	target := d.Find(path)
+	if target == nil {
+		return false
+	}
+	if removeNode(&d.Nodes, target) {
+		return true
+	}
+	var walk func(nodes []*Node) bool
+	walk = func(nodes []*Node) bool {
+		for _, node := range nodes {
+			if removeNode(&node.Children, target) || walk(node.Children) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(d.Nodes)
+}
+
+func removeNode(nodes *[]*Node, target *Node) bool {
+	for i, node := range *nodes {
+		if node == target {
+			*nodes = append((*nodes)[:i], (*nodes)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// splitPath splits a path like "servers[0].host" into ["servers", "0", "host"].
+func splitPath(path string) []string {
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	var segments []string
+	for _, segment := range strings.Split(path, ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
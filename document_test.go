@@ -0,0 +1,100 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const documentContent = `# Servers for every environment
+environments: &environments
+  - dev   # development
+  - prod
+
+color: "#fff" # quoted hash
+title: it's fine
+
+servers:
+  !for idx, name in *environments:
+    - name: *name
+      ip: 192.168.1.${(idx + 1) * 100}
+
+defaults: &defaults
+    port:   22 # ssh
+custom:
+    <<: *defaults
+# trailing comment
+`
+
+func TestDocumentRoundTrip(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentContent))
+	assert.NoError(t, err)
+	assert.Equal(t, documentContent, string(doc.Bytes()))
+
+	loop := doc.Find("servers").Children[0]
+	assert.Equal(t, LOOP, loop.Type)
+	assert.Equal(t, "idx, name", loop.Key)
+	assert.Equal(t, "*environments", loop.Value)
+	assert.Equal(t, "name", loop.Children[0].Alias)
+
+	color := doc.Find("color")
+	assert.Equal(t, `"#fff"`, color.Value)
+	assert.Equal(t, "# quoted hash", color.Comment)
+	assert.Equal(t, "it's fine", doc.Find("title").Value)
+	assert.Equal(t, "environments", doc.Find("environments").Anchor)
+	assert.Equal(t, "prod", doc.Find("environments[1]").Value)
+	assert.Equal(t, "defaults", doc.Find("custom").Children[0].Alias)
+}
+
+func TestDocumentEdit(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentContent))
+	assert.NoError(t, err)
+
+	doc.Find("defaults.port").Value = "2222"
+	doc.Find("environments").Children = append(doc.Find("environments").Children, &Node{Type: LIST_ITEM, Value: "staging"})
+	doc.Find("defaults").Children = append(doc.Find("defaults").Children, &Node{Type: KEY, Key: "user", Value: "${upper(\"root\")}"})
+	assert.True(t, doc.Remove("title"))
+	assert.False(t, doc.Remove("missing"))
+
+	expected := `# Servers for every environment
+environments: &environments
+  - dev   # development
+  - prod
+  - staging
+
+color: "#fff" # quoted hash
+
+servers:
+  !for idx, name in *environments:
+    - name: *name
+      ip: 192.168.1.${(idx + 1) * 100}
+
+defaults: &defaults
+    port: 2222 # ssh
+    user: ${upper("root")}
+custom:
+    <<: *defaults
+# trailing comment
+`
+	assert.Equal(t, expected, string(doc.Bytes()))
+
+	tokens, err := doc.Tokens()
+	assert.NoError(t, err)
+	result, err := Parse(tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"dev", "prod", "staging"}, result["environments"])
+	assert.Equal(t, map[string]any{"port": int64(2222), "user": "ROOT"}, result["custom"])
+}
+
+func TestDocumentReadsLinesLikeTokenize(t *testing.T) {
+	for _, content := range []string{"a:1", "- http://example.com", "note: use !for loops", "-", "<<: base"} {
+		_, tokenizeErr := Tokenize([]string{content}, 0)
+		_, documentErr := ParseDocument([]byte(content))
+		assert.Equal(t, tokenizeErr == nil, documentErr == nil, content)
+	}
+
+	doc, err := ParseDocument([]byte("a:1\nlist:\n  - http://example.com"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", doc.Find("a").Value)
+	assert.Equal(t, "http", doc.Find("list[0]").Key)
+	assert.Equal(t, "//example.com", doc.Find("list[0]").Value)
+}
@@ -0,0 +1,316 @@
+package yamlx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EncodeJSON encodes a value from ParseOrdered or Parse as JSON. OrderedMaps
+// keep their key order and plain maps are written with their keys sorted, so
+// the output is deterministic. An empty indent gives compact JSON.
+func EncodeJSON(value any, indent string) ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeJSON(&b, value, ""); err != nil {
+		return nil, err
+	}
+	if indent == "" {
+		return b.Bytes(), nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, b.Bytes(), "", indent); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// RenderJSON evaluates a yamlx template and returns the result as JSON,
+// indented by Options.Indent spaces, or compact if it is 0.
+func RenderJSON(data []byte, opts Options) ([]byte, error) {
+	result, _, err := evaluate(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	output, err := EncodeJSON(result, strings.Repeat(" ", opts.Indent))
+	if err != nil {
+		return nil, err
+	}
+	return append(output, '\n'), nil
+}
+
+// MarshalJSON encodes the map with its keys in order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	return EncodeJSON(m, "")
+}
+
+func writeJSON(b *bytes.Buffer, value any, path string) error {
+	switch v := value.(type) {
+	case *OrderedMap:
+		return writeJSONObject(b, v.keys, func(k string) any { return v.values[k] }, path)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return writeJSONObject(b, keys, func(k string) any { return v[k] }, path)
+	case []any:
+		b.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeJSON(b, elem, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+		return nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("yamlx: json can't represent %v at %s", v, displayPath(path))
+		}
+	}
+	if err := writeJSONScalar(b, value); err != nil {
+		return fmt.Errorf("yamlx: json can't represent the value at %s: %w", displayPath(path), err)
+	}
+	return nil
+}
+
+// writeJSONScalar encodes a single value without escaping HTML characters,
+// which are common in config values.
+func writeJSONScalar(b *bytes.Buffer, value any) error {
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	b.Truncate(b.Len() - 1) // Encode adds a newline
+	return nil
+}
+
+func writeJSONObject(b *bytes.Buffer, keys []string, get func(string) any, path string) error {
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeJSONScalar(b, k)
+		b.WriteByte(':')
+		if err := writeJSON(b, get(k), joinPath(path, k)); err != nil {
+			return err
+		}
+	}
+	b.WriteByte('}')
+	return nil
+}
+
+// EncodeTOML encodes a value from ParseOrdered as TOML. Nested mappings
+// become tables and lists of mappings become arrays of tables. Values TOML
+// has no way to represent, like null, are reported as errors.
+func EncodeTOML(value *OrderedMap) ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeTOMLTable(&b, value, nil); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// RenderTOML evaluates a yamlx template and returns the result as TOML.
+func RenderTOML(data []byte, opts Options) ([]byte, error) {
+	result, _, err := evaluate(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeTOML(result)
+}
+
+// writeTOMLTable writes the body of a table: its plain values first, then its
+// sub-tables and arrays of tables, which must come after them in TOML.
+func writeTOMLTable(b *bytes.Buffer, table *OrderedMap, keys []string) error {
+	var nested []string
+	for _, k := range table.keys {
+		value := table.values[k]
+		if toOrderedMap(value) != nil || isTableArray(value) {
+			nested = append(nested, k)
+			continue
+		}
+		b.WriteString(tomlKey(k) + " = ")
+		if err := writeTOMLValue(b, value, append(keys, k)); err != nil {
+			return err
+		}
+		b.WriteByte('\n')
+	}
+
+	for _, k := range nested {
+		childKeys := append(append([]string(nil), keys...), k)
+		header := tomlHeader(childKeys)
+		if sub := toOrderedMap(table.values[k]); sub != nil {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString("[" + header + "]\n")
+			if err := writeTOMLTable(b, sub, childKeys); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, elem := range table.values[k].([]any) {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString("[[" + header + "]]\n")
+			if err := writeTOMLTable(b, toOrderedMap(elem), childKeys); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeTOMLValue writes a value on the right hand side of a key, using inline
+// tables for mappings inside lists.
+func writeTOMLValue(b *bytes.Buffer, value any, keys []string) error {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Errorf("yamlx: toml can't represent null at %s", displayPath(tomlPath(keys)))
+	case string:
+		b.WriteString(tomlString(v))
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			b.WriteString("nan")
+		case math.IsInf(v, 1):
+			b.WriteString("inf")
+		case math.IsInf(v, -1):
+			b.WriteString("-inf")
+		default:
+			s := strconv.FormatFloat(v, 'g', -1, 64)
+			if !strings.ContainsAny(s, ".eEn") {
+				s += ".0"
+			}
+			b.WriteString(s)
+		}
+	case []any:
+		b.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if err := writeTOMLValue(b, elem, append(keys, fmt.Sprintf("[%d]", i))); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	default:
+		table := toOrderedMap(value)
+		if table == nil {
+			return fmt.Errorf("yamlx: toml can't represent %T at %s", value, displayPath(tomlPath(keys)))
+		}
+		b.WriteByte('{')
+		for i, k := range table.keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(tomlKey(k) + " = ")
+			if err := writeTOMLValue(b, table.values[k], append(keys, k)); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	}
+	return nil
+}
+
+// isTableArray reports whether a value is a non-empty list made only of
+// mappings, which TOML writes as an array of tables.
+func isTableArray(value any) bool {
+	l, ok := value.([]any)
+	if !ok || len(l) == 0 {
+		return false
+	}
+	for _, elem := range l {
+		if toOrderedMap(elem) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+var bareKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlKey returns a key, quoted if it can't be written bare.
+func tomlKey(key string) string {
+	if bareKeyRegex.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString quotes a basic string, using only the escapes TOML supports.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func tomlHeader(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		quoted[i] = tomlKey(k)
+	}
+	return strings.Join(quoted, ".")
+}
+
+// tomlPath joins keys into a document path for error messages.
+func tomlPath(keys []string) string {
+	path := ""
+	for _, k := range keys {
+		if strings.HasPrefix(k, "[") {
+			path += k
+		} else {
+			path = joinPath(path, k)
+		}
+	}
+	return path
+}
+
+// displayPath names a path in error messages, using "the root" for "".
+func displayPath(path string) string {
+	if path == "" {
+		return "the root"
+	}
+	return path
+}
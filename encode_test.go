@@ -0,0 +1,91 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+const encodeContent = `
+title: Servers
+version: 1.0
+defaults: &defaults
+  port: 22
+  tags: [a, b]
+servers:
+  !for name in [web, db]:
+    - name: ${name}
+      enabled: ${name == "web"}
+      meta:
+        zone: eu
+mixed:
+  - 1
+  - key: value
+`
+
+func TestRenderJSON(t *testing.T) {
+	output, err := RenderJSON([]byte(encodeContent), Options{})
+	expected := `{"title":"Servers","version":1,"defaults":{"port":22,"tags":["a","b"]},` +
+		`"servers":[{"name":"web","enabled":true,"meta":{"zone":"eu"}},{"name":"db","enabled":false,"meta":{"zone":"eu"}}],` +
+		`"mixed":[1,{"key":"value"}]}` + "\n"
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(output))
+
+	output, err = RenderJSON([]byte("a:\n  b: [1, 2]\n"), Options{Indent: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": {\n    \"b\": [\n      1,\n      2\n    ]\n  }\n}\n", string(output))
+}
+
+func TestEncodeJSON(t *testing.T) {
+	output, err := EncodeJSON(map[string]any{"b": "<1>", "a": []any{true, nil}}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":[true,null],"b":"<1>"}`, string(output))
+
+	_, err = EncodeJSON(map[string]any{"a": []any{math.Inf(1)}}, "")
+	assert.EqualError(t, err, "yamlx: json can't represent +Inf at a[0]")
+}
+
+func TestRenderTOML(t *testing.T) {
+	output, err := RenderTOML([]byte(encodeContent), Options{})
+	expected := `title = "Servers"
+version = 1.0
+mixed = [1, {key = "value"}]
+
+[defaults]
+port = 22
+tags = ["a", "b"]
+
+[[servers]]
+name = "web"
+enabled = true
+
+[servers.meta]
+zone = "eu"
+
+[[servers]]
+name = "db"
+enabled = false
+
+[servers.meta]
+zone = "eu"
+`
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(output))
+}
+
+func TestEncodeTOMLErrors(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("list", []any{"a", nil})
+	_, err := EncodeTOML(m)
+	assert.EqualError(t, err, "yamlx: toml can't represent null at list[1]")
+
+	m = NewOrderedMap()
+	m.Set("odd key", 1)
+	output, err := EncodeTOML(m)
+	assert.NoError(t, err)
+	assert.Equal(t, "\"odd key\" = 1\n", string(output))
+}
+
+func TestTOMLString(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c\n\u0007é"`, tomlString("a\"b\\c\n\aé"))
+}
@@ -0,0 +1,206 @@
+package yamlx
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"hash/crc32"
+	"net/url"
+	"strings"
+)
+
+// textArg returns the single argument of a function that works on text,
+// written as it would appear in output.
+func textArg(name string, args []any) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("%s function requires 1 argument", name)
+	}
+	switch args[0].(type) {
+	case string, float64, int64, bool:
+		return textValue(args[0]), nil
+	}
+	return "", fmt.Errorf("%s function requires a string", name)
+}
+
+func b64enc(args ...any) (any, error) {
+	s, err := textArg("b64enc", args)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(s)), nil
+}
+
+func b64dec(args ...any) (any, error) {
+	s, err := textArg("b64dec", args)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("b64dec function requires valid base64: %w", err)
+	}
+	return string(decoded), nil
+}
+
+func hexEncode(args ...any) (any, error) {
+	s, err := textArg("hex", args)
+	if err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString([]byte(s)), nil
+}
+
+func urlencode(args ...any) (any, error) {
+	s, err := textArg("urlencode", args)
+	if err != nil {
+		return nil, err
+	}
+	return url.QueryEscape(s), nil
+}
+
+func sha256sum(args ...any) (any, error) {
+	s, err := textArg("sha256", args)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s))), nil
+}
+
+func sha1sum(args ...any) (any, error) {
+	s, err := textArg("sha1", args)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(s))), nil
+}
+
+func md5sum(args ...any) (any, error) {
+	s, err := textArg("md5", args)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(s))), nil
+}
+
+func crc32sum(args ...any) (any, error) {
+	s, err := textArg("crc32", args)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(s))), nil
+}
+
+func tojson(args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("tojson function requires 1 argument")
+	}
+	output, err := json.Marshal(plainList(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("tojson function: %w", err)
+	}
+	return string(output), nil
+}
+
+func fromjson(args ...any) (any, error) {
+	s, err := stringArg("fromjson", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil, fmt.Errorf("fromjson function requires valid JSON: %w", err)
+	}
+	return decodedValue(value), nil
+}
+
+func toyaml(args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("toyaml function requires 1 argument")
+	}
+	output, err := yaml.Marshal(plainList(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("toyaml function: %w", err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func fromyaml(args ...any) (any, error) {
+	s, err := stringArg("fromyaml", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := yaml.Unmarshal([]byte(s), &value); err != nil {
+		return nil, fmt.Errorf("fromyaml function requires valid YAML: %w", err)
+	}
+	return decodedValue(value), nil
+}
+
+// decodedValue converts a decoded JSON or YAML value to the types anchors
+// hold, with a List at the top so it reaches other functions whole.
+func decodedValue(value any) any {
+	var convert func(value any) any
+	convert = func(value any) any {
+		switch v := value.(type) {
+		case int:
+			return int64(v)
+		case []any:
+			for i, elem := range v {
+				v[i] = convert(elem)
+			}
+			return v
+		case map[string]any:
+			for k, elem := range v {
+				v[k] = convert(elem)
+			}
+			return v
+		case map[any]any:
+			m := make(map[string]any, len(v))
+			for k, elem := range v {
+				m[fmt.Sprint(k)] = convert(elem)
+			}
+			return m
+		}
+		return value
+	}
+	value = convert(value)
+	if l, ok := value.([]any); ok {
+		return List(l)
+	}
+	return value
+}
+
+// uuidNamespaces are the namespaces RFC 4122 defines, by name.
+var uuidNamespaces = map[string]string{
+	"dns":  "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"url":  "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+	"oid":  "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+	"x500": "6ba7b814-9dad-11d1-80b4-00c04fd430c8",
+}
+
+func uuid5(args ...any) (any, error) {
+	strs, err := stringArgs("uuid5", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	namespace := strs[0]
+	if known, ok := uuidNamespaces[strings.ToLower(namespace)]; ok {
+		namespace = known
+	}
+	ns, err := hex.DecodeString(strings.ReplaceAll(namespace, "-", ""))
+	if err != nil || len(ns) != 16 {
+		return nil, fmt.Errorf("uuid5 function requires a UUID or dns, url, oid or x500 as the namespace")
+	}
+	h := sha1.New()
+	h.Write(ns)
+	h.Write([]byte(strs[1]))
+	u := h.Sum(nil)[:16]
+	u[6] = (u[6] & 0x0f) | 0x50 // Version 5
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]), nil
+}
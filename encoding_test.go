@@ -0,0 +1,64 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestEncodingFunctions(t *testing.T) {
+	tests := map[string]any{
+		`b64enc("hello")`:                     "aGVsbG8=",
+		`b64dec("aGVsbG8=")`:                  "hello",
+		`hex("hi")`:                           "6869",
+		`urlencode("a b&c")`:                  "a+b%26c",
+		`sha256("hello")`:                     "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		`sha1("hello")`:                       "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		`md5("hello")`:                        "5d41402abc4b2a76b9719d911017c592",
+		`crc32("hello")`:                      "3610a686",
+		`tojson(["a", 1])`:                    `["a",1]`,
+		`tojson(fromjson("{\"b\": [1, 2]}"))`: `{"b":[1,2]}`,
+		`len(fromjson("[1, 2, 3]"))`:          3,
+		`uuid5("dns", "www.example.com")`:     "2ed6657d-e927-568b-95e1-2665a8aea6a2",
+		`uuid5("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "www.example.com")`: "2ed6657d-e927-568b-95e1-2665a8aea6a2",
+	}
+	for expression, expected := range tests {
+		result, err := Eval([]byte("a: 1"), expression, Options{})
+		assert.NoError(t, err, expression)
+		assert.Equal(t, expected, result, expression)
+	}
+
+	for _, expression := range []string{`b64dec("!!")`, `fromjson("{")`, `uuid5("nope", "a")`, `sha256(["a"])`} {
+		_, err := Eval([]byte("a: 1"), expression, Options{})
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestEncodedValuesInDocuments(t *testing.T) {
+	out, err := RenderJSON([]byte(`
+hex: ${hex("hi")}
+decoded: ${b64dec("MTIz")}
+json: ${tojson(42)}
+parsed: ${fromjson("42")}
+crc: ${crc32("hello")}
+`), Options{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"hex":"6869","decoded":"123","json":"42","parsed":42,"crc":"3610a686"}`, string(out))
+}
+
+func TestYAMLFunctions(t *testing.T) {
+	yamlContent := `
+db: &db
+  host: localhost
+  port: 5432
+inline: ${toyaml(db)}
+name: ${fromyaml("name: web")}
+ports: ${fromyaml("[80, 443]")}
+`
+	tokens, _ := Tokenize(strings.Split(yamlContent, "\n"), 0)
+	result, err := Parse(tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, "host: localhost\nport: 5432", result["inline"])
+	assert.Equal(t, map[string]any{"name": "web"}, result["name"])
+	assert.Equal(t, []any{int64(80), int64(443)}, result["ports"])
+}
@@ -0,0 +1,179 @@
+package yamlx
+
+import (
+	"strings"
+)
+
+// Format re-emits yamlx source in canonical form: nested blocks indented by
+// two spaces, one space inside `!for` headers and after commas in flow lists,
+// no padding inside `${ }` and double-quoted strings in expressions. Comments
+// are kept and runs of blank lines are collapsed. Values are otherwise left as
+// written, since yamlx reads quotes and spacing in them literally.
+func Format(data []byte) ([]byte, error) {
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	lines = appendFormattedLines(lines, doc.Nodes, 0)
+	lines = appendBlankAndComments(lines, doc.Trailing, 0)
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+func appendFormattedLines(lines []string, nodes []*Node, indent int) []string {
+	for _, n := range nodes {
+		lines = appendBlankAndComments(lines, n.Head, indent)
+		n.Indent = indent
+		switch n.Type {
+		case LOOP:
+			variables := strings.Split(n.Key, ",")
+			for i, variable := range variables {
+				variables[i] = strings.TrimSpace(variable)
+			}
+			n.Key = strings.Join(variables, ", ")
+			n.Value = formatValue(n.Value)
+		case MERGE_KEY:
+			n.Alias = strings.TrimSpace(n.Alias)
+		default:
+			n.Value = formatValue(n.Value)
+		}
+		lines = append(lines, n.Format())
+		lines = appendFormattedLines(lines, n.Children, indent+2)
+	}
+	return lines
+}
+
+// appendBlankAndComments appends the comment lines before a node, indented
+// like the node, keeping at most one blank line in a row. Blank lines at the
+// very start of the file are dropped.
+func appendBlankAndComments(lines []string, head []string, indent int) []string {
+	for _, line := range head {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if len(lines) > 0 && lines[len(lines)-1] != "" {
+				lines = append(lines, "")
+			}
+			continue
+		}
+		lines = append(lines, strings.Repeat(" ", indent)+line)
+	}
+	return lines
+}
+
+// formatValue normalises the expressions in a value and the spacing of a flow
+// list.
+func formatValue(value string) string {
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") && !strings.Contains(value, "${") {
+		return "[" + formatList(value[1:len(value)-1]) + "]"
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			break
+		}
+		end := expressionEnd(value, start+2)
+		if end < 0 {
+			break
+		}
+		b.WriteString(value[:start])
+		b.WriteString("${" + formatExpression(value[start+2:end]) + "}")
+		value = value[end+1:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
+
+// expressionEnd returns the index of the brace closing an expression whose
+// body starts at i, skipping braces inside string literals, or -1.
+func expressionEnd(s string, i int) int {
+	var quote byte
+	for ; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// formatList puts one space after each comma of a flow list, leaving quoted
+// items alone.
+func formatList(items string) string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(items); i++ {
+		switch ch := items[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ',':
+			parts = append(parts, strings.TrimSpace(items[start:i]))
+			start = i + 1
+		}
+	}
+	parts = append(parts, strings.TrimSpace(items[start:]))
+	return strings.Join(parts, ", ")
+}
+
+// formatExpression trims the padding of an expression, collapses runs of
+// spaces, puts one space after commas and none inside parentheses, and
+// double-quotes string literals that don't contain one.
+func formatExpression(expression string) string {
+	var b strings.Builder
+	expression = strings.TrimSpace(expression)
+	for i := 0; i < len(expression); i++ {
+		ch := expression[i]
+		switch {
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(expression[i+1:], ch)
+			if end < 0 {
+				b.WriteString(expression[i:])
+				return b.String()
+			}
+			literal := expression[i+1 : i+1+end]
+			if ch == '\'' && !strings.Contains(literal, `"`) {
+				ch = '"'
+			}
+			b.WriteByte(ch)
+			b.WriteString(literal)
+			b.WriteByte(ch)
+			i += end + 1
+		case ch == ' ' || ch == '\t':
+			for i+1 < len(expression) && (expression[i+1] == ' ' || expression[i+1] == '\t') {
+				i++
+			}
+			if i+1 < len(expression) && expression[i+1] != ',' && expression[i+1] != ')' {
+				b.WriteByte(' ')
+			}
+		case ch == ',' || ch == '(':
+			if ch == ',' {
+				b.WriteString(", ")
+			} else {
+				b.WriteByte(ch)
+			}
+			for i+1 < len(expression) && (expression[i+1] == ' ' || expression[i+1] == '\t') {
+				i++
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
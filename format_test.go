@@ -0,0 +1,56 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	yamlContent := `
+
+# Environments to deploy
+environments: &environments [dev,prod ,  'qa']
+subdomains: &subdomains [example, myapp]   # trailing comment
+
+
+servers:
+    !for  idx ,name   in *environments :
+        - name: *name
+          host: ${ name }.${join( '.',subdomains )}.com
+          port:   22
+            # nested comment
+          tags: [${idx}, ${  name  }]
+`
+	expected := `# Environments to deploy
+environments: &environments [dev, prod, 'qa']
+subdomains: &subdomains [example, myapp] # trailing comment
+
+servers:
+  !for idx, name in *environments:
+    - name: *name
+      host: ${name}.${join(".", subdomains)}.com
+      port: 22
+      # nested comment
+      tags: [${idx}, ${name}]
+`
+	formatted, err := Format([]byte(yamlContent))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(formatted))
+
+	// Formatting doesn't change what the template evaluates to
+	before, err := Tokenize(strings.Split(yamlContent, "\n"), 0)
+	assert.Nil(t, err)
+	after, err := Tokenize(strings.Split(expected, "\n"), 0)
+	assert.Nil(t, err)
+	beforeResult, err := Parse(before)
+	assert.Nil(t, err)
+	afterResult, err := Parse(after)
+	assert.Nil(t, err)
+	assert.Equal(t, beforeResult, afterResult)
+
+	// Formatting is idempotent
+	again, err := Format(formatted)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(again))
+}
@@ -2,17 +2,119 @@ package yamlx
 
 import (
 	"fmt"
-	"github.com/Knetic/govaluate"
-	"math/rand"
+	"math"
+	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
+// Function describes a function that expressions can call.
+type Function struct {
+	Name        string
+	Signature   string // e.g. "join(string, []any)"
+	Description string
+}
+
+var functionDocs = map[string]Function{
+	"len":            {"len", "len(string | []any | map)", "Returns the number of characters in a string, items in a slice or keys in a map."},
+	"contains":       {"contains", "contains(string | []any, any)", "Checks if a string contains a substring or if a slice contains an element."},
+	"rand":           {"rand", "rand(float64, float64 | []any)", "Generates a random number between two numbers, inclusive if both are whole, or selects a random element from a slice."},
+	"randint":        {"randint", "randint(float64, float64)", "Generates a random whole number between two whole numbers, inclusive."},
+	"randf":          {"randf", "randf([float64, float64])", "Generates a random float from the first number up to the second, or from 0 up to 1."},
+	"choice":         {"choice", "choice([]any)", "Selects a random element from a slice."},
+	"shuffle":        {"shuffle", "shuffle([]any)", "Returns the elements of a slice in a random order."},
+	"sample":         {"sample", "sample([]any, float64)", "Selects a number of distinct random elements from a slice."},
+	"randstr":        {"randstr", "randstr(float64[, string])", "Generates a random string of a given length, from letters and digits or the given characters."},
+	"max":            {"max", "max(...float64)", "Finds the maximum value among the arguments."},
+	"min":            {"min", "min(...float64)", "Finds the minimum value among the arguments."},
+	"upper":          {"upper", "upper(string)", "Converts a string to uppercase."},
+	"lower":          {"lower", "lower(string)", "Converts a string to lowercase."},
+	"title":          {"title", "title(string)", "Converts a string to title case."},
+	"trim":           {"trim", "trim(string)", "Trims leading and trailing spaces from a string."},
+	"join":           {"join", "join(string, []any)", "Joins elements of a slice into a string separated by a delimiter."},
+	"replace":        {"replace", "replace(string, string, string)", "Replaces occurrences of a substring within a string."},
+	"substr":         {"substr", "substr(string, float64[, float64])", "Extracts the characters from a start index up to an end index, or to the end of the string."},
+	"strrev":         {"strrev", "strrev(string)", "Reverses a string."},
+	"startswith":     {"startswith", "startswith(string, string)", "Checks if a string starts with a substring."},
+	"endswith":       {"endswith", "endswith(string, string)", "Checks if a string ends with a substring."},
+	"alltrue":        {"alltrue", "alltrue(...bool)", "Checks if all of the arguments are true."},
+	"anytrue":        {"anytrue", "anytrue(...bool)", "Checks if any of the arguments are true."},
+	"keys":           {"keys", "keys(map)", "Returns the keys of a map, sorted."},
+	"values":         {"values", "values(map)", "Returns the values of a map, in the order of its sorted keys."},
+	"sort":           {"sort", "sort([]any)", "Sorts a slice of numbers or strings."},
+	"sortby":         {"sortby", "sortby([]map, string)", "Sorts a slice of maps by the value of a key."},
+	"uniq":           {"uniq", "uniq([]any)", "Removes repeated elements from a slice, keeping the first of each."},
+	"reverse":        {"reverse", "reverse([]any)", "Reverses a slice."},
+	"flatten":        {"flatten", "flatten([]any)", "Flattens nested slices into a single slice."},
+	"concat":         {"concat", "concat(...[]any)", "Joins slices together into one slice."},
+	"slice":          {"slice", "slice([]any, float64[, float64])", "Returns the elements from a start index up to an end index, counting back from the end for negative indexes."},
+	"first":          {"first", "first([]any)", "Returns the first element of a slice."},
+	"last":           {"last", "last([]any)", "Returns the last element of a slice."},
+	"range":          {"range", "range([float64, ]float64[, float64])", "Returns the whole numbers from a start, or 0, up to an end, by an optional step."},
+	"zip":            {"zip", "zip(...[]any)", "Pairs up the elements of slices by index, stopping at the shortest."},
+	"get":            {"get", "get(map, string[, any])", "Returns the value at a dotted path in a map, or a default if there is none."},
+	"has":            {"has", "has(map, string)", "Checks if a map has a value at a dotted path."},
+	"merge":          {"merge", "merge(...map)", "Merges maps, later ones winning, including the maps nested in them."},
+	"pick":           {"pick", "pick(map, ...string)", "Returns a map with only the given keys."},
+	"omit":           {"omit", "omit(map, ...string)", "Returns a map without the given keys."},
+	"dict":           {"dict", "dict(...string, any)", "Builds a map from pairs of keys and values."},
+	"items":          {"items", "items(map)", "Returns the entries of a map as maps with a key and a value, sorted by key."},
+	"format":         {"format", "format(string, ...any)", "Formats values with a printf-style format string, like \"%s-%03d\"."},
+	"printf":         {"printf", "printf(string, ...any)", "The same as format."},
+	"pad":            {"pad", "pad(any, float64[, string])", "Pads a value on the left to a width, or on the right for a negative width, with spaces or the given character."},
+	"regex_match":    {"regex_match", "regex_match(string, string)", "Checks if a string matches a regular expression."},
+	"regex_replace":  {"regex_replace", "regex_replace(string, string, string)", "Replaces the matches of a regular expression in a string, expanding $1 and the like."},
+	"regex_find_all": {"regex_find_all", "regex_find_all(string, string)", "Returns the matches of a regular expression in a string."},
+	"split":          {"split", "split(string, string)", "Splits a string on a separator."},
+	"indent":         {"indent", "indent(string, float64)", "Indents each line of a string by a number of spaces."},
+	"quote":          {"quote", "quote(string)", "Wraps a string in double quotes, escaping it."},
+	"truncate":       {"truncate", "truncate(string, float64[, string])", "Shortens a string to a number of characters, ending it with the given suffix if it was cut."},
+	"slugify":        {"slugify", "slugify(string)", "Converts a string to lowercase words joined by hyphens, for names and URLs."},
+	"camel":          {"camel", "camel(string)", "Converts a string to camelCase."},
+	"snake":          {"snake", "snake(string)", "Converts a string to snake_case."},
+	"kebab":          {"kebab", "kebab(string)", "Converts a string to kebab-case."},
+	"b64enc":         {"b64enc", "b64enc(string)", "Encodes a string as base64."},
+	"b64dec":         {"b64dec", "b64dec(string)", "Decodes a base64 string."},
+	"hex":            {"hex", "hex(string)", "Encodes a string as hexadecimal."},
+	"urlencode":      {"urlencode", "urlencode(string)", "Escapes a string for use in a URL query."},
+	"sha256":         {"sha256", "sha256(string)", "Returns the SHA-256 hash of a string, in hexadecimal."},
+	"sha1":           {"sha1", "sha1(string)", "Returns the SHA-1 hash of a string, in hexadecimal."},
+	"md5":            {"md5", "md5(string)", "Returns the MD5 hash of a string, in hexadecimal."},
+	"crc32":          {"crc32", "crc32(string)", "Returns the CRC-32 checksum of a string, in hexadecimal."},
+	"tojson":         {"tojson", "tojson(any)", "Encodes a value as compact JSON."},
+	"fromjson":       {"fromjson", "fromjson(string)", "Decodes a JSON string into a value."},
+	"toyaml":         {"toyaml", "toyaml(any)", "Encodes a value as YAML."},
+	"fromyaml":       {"fromyaml", "fromyaml(string)", "Decodes a YAML string into a value."},
+	"uuid5":          {"uuid5", "uuid5(string, string)", "Returns the version 5 UUID of a name in a namespace, either a UUID or dns, url, oid or x500."},
+	"map":            {"map", "map([]any, string)", "Applies a lambda like \"x => x * 2\" to each element of a slice."},
+	"filter":         {"filter", "filter([]any, string)", "Keeps the elements of a slice that a lambda like \"x => x > 2\" returns true for."},
+}
+
+// Functions returns the functions expressions can call, sorted by name.
+func Functions() []Function {
+	result := make([]Function, 0, len(functions))
+	for name := range functions {
+		doc, ok := functionDocs[name]
+		if !ok {
+			doc = Function{Name: name, Signature: name + "(...)"}
+		}
+		result = append(result, doc)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
 func length(args ...any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("len function requires 1 argument")
+	}
 	if strval, ok := args[0].(string); ok {
-		length := len(strval)
+		length := utf8.RuneCountInString(strval)
 		return (float64)(length), nil
+	} else if m, ok := args[0].(map[string]any); ok && len(args) == 1 {
+		return len(m), nil
 	} else {
-		return len(args), nil
+		return len(spread(args)), nil
 	}
 }
 
@@ -25,41 +127,22 @@ func contains(args ...any) (any, error) {
 			return strings.Contains(strval, substrval), nil
 		}
 	} else {
-		found := false
-		for _, v := range args[:len(args)-1] {
-			expression, err := govaluate.NewEvaluableExpression(fmt.Sprintf("%v == %v", v, args[len(args)-1]))
-			if err != nil {
-				return nil, err
-			}
-			result, err := expression.Evaluate(nil)
-			if err != nil {
-				return nil, err
-			}
-			if result.(bool) {
-				found = true
-				break
+		items := args[:len(args)-1]
+		if list, ok := args[0].(List); ok && len(args) == 2 {
+			items = list
+		}
+		for _, v := range items {
+			if equalValues(v, args[len(args)-1]) {
+				return true, nil
 			}
 		}
-		return found, nil
+		return false, nil
 	}
 	return nil, fmt.Errorf("contains function requires string arguments")
 }
 
-func calcRand(args ...any) (any, error) {
-	if min, ok := args[0].(float64); ok && len(args) == 2 {
-		if max, ok := args[1].(float64); ok {
-			return rand.Int63n(int64(max-min)) + int64(min), nil
-		} else {
-			return nil, fmt.Errorf("rand function requires 2 integer arguments")
-		}
-	} else {
-		// select random option in args
-		return args[rand.Intn(len(args))], nil
-	}
-	return nil, fmt.Errorf("invalid arguments for rand function")
-}
-
 func calcMax(args ...any) (any, error) {
+	args = spread(args)
 	if len(args) < 1 {
 		return nil, fmt.Errorf("max function requires at least 1 argument")
 	}
@@ -81,6 +164,7 @@ func calcMax(args ...any) (any, error) {
 }
 
 func calcMin(args ...any) (any, error) {
+	args = spread(args)
 	if len(args) < 1 {
 		return nil, fmt.Errorf("min function requires at least 1 argument")
 	}
@@ -146,7 +230,7 @@ func join(args ...any) (any, error) {
 		return nil, fmt.Errorf("join function requires 2 arguments")
 	}
 	if strval, ok := args[0].(string); ok {
-		if slice, ok := args[1].([]any); ok {
+		if slice, ok := listItems(args[1]); ok && len(args) == 2 {
 			strs := make([]string, len(slice))
 			for i, v := range slice {
 				strs[i] = fmt.Sprintf("%v", v)
@@ -179,15 +263,24 @@ func replace(args ...any) (any, error) {
 }
 
 func substr(args ...any) (any, error) {
-	if len(args) != 3 {
-		return nil, fmt.Errorf("substr function requires 3 arguments")
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("substr function requires 2 or 3 arguments")
 	}
 	if strval, ok := args[0].(string); ok {
-		if startval, ok := args[1].(float64); ok {
-			if endval, ok := args[2].(float64); ok {
-				return strval[int(startval):int(endval)], nil
+		runes := []rune(strval)
+		bounds := []int{0, len(runes)}
+		for i, arg := range args[1:] {
+			bound, ok := arg.(float64)
+			if !ok {
+				return nil, fmt.Errorf("substr function requires string and integer arguments")
 			}
+			// Indexes are in characters, and kept within the string
+			bounds[i] = int(math.Max(0, math.Min(bound, float64(len(runes)))))
+		}
+		if bounds[1] < bounds[0] {
+			return "", nil
 		}
+		return string(runes[bounds[0]:bounds[1]]), nil
 	}
 	return nil, fmt.Errorf("substr function requires string and integer arguments")
 }
@@ -231,6 +324,7 @@ func endswith(args ...any) (any, error) {
 }
 
 func alltrue(args ...any) (any, error) {
+	args = spread(args)
 	if len(args) < 1 {
 		return nil, fmt.Errorf("alltrue function requires at least 1 argument")
 	}
@@ -247,6 +341,7 @@ func alltrue(args ...any) (any, error) {
 }
 
 func anytrue(args ...any) (any, error) {
+	args = spread(args)
 	if len(args) < 1 {
 		return nil, fmt.Errorf("anytrue function requires at least 1 argument")
 	}
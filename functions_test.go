@@ -1,6 +1,8 @@
 package yamlx
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"strings"
 	"testing"
@@ -112,3 +114,120 @@ random:
 
 	assert.NoError(t, err)
 }
+
+func TestSeededRandomFunctions(t *testing.T) {
+	yamlContent := []byte(`
+names: &names [foo, bar, baz, qux]
+number: ${rand(1, 1000)}
+pick: ${rand(names)}
+order: ${join(",", shuffle(names))}
+two: ${join(",", sample(names, 2))}
+token: ${randstr(12)}
+digits: ${randstr(6, "0123456789")}
+`)
+	render := func(opts Options) map[string]any {
+		var result map[string]any
+		out, err := RenderJSON(yamlContent, opts)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(out, &result))
+		return result
+	}
+
+	// The same document renders the same way
+	first := render(Options{})
+	assert.Equal(t, first, render(Options{}))
+	assert.NotEqual(t, render(Options{Seed: 1}), render(Options{Seed: 2}))
+	assert.Equal(t, render(Options{Seed: 1}), render(Options{Seed: 1}))
+
+	assert.ElementsMatch(t, []string{"foo", "bar", "baz", "qux"}, strings.Split(first["order"].(string), ","))
+	two := strings.Split(first["two"].(string), ",")
+	assert.Len(t, two, 2)
+	assert.NotEqual(t, two[0], two[1])
+	assert.Regexp(t, `^[a-zA-Z0-9]{12}$`, first["token"])
+	assert.Regexp(t, `^[0-9]{6}$`, fmt.Sprint(first["digits"]))
+
+	_, err := RenderJSON([]byte("x: ${sample(names, 5)}\nnames: &names [a, b]"), Options{})
+	assert.Error(t, err)
+}
+
+func TestRandomSemantics(t *testing.T) {
+	yamlContent := []byte(`
+fruits: &fruits [apple, banana, cherry]
+bounds: &bounds [5, 10]
+same: ${rand(3, 3)}
+whole: ${rand(1, 2)}
+float: ${rand(0.5, 1.5)}
+int: ${randint(1, 6)}
+unit: ${randf()}
+ranged: ${randf(2, 3)}
+anchor: ${choice(fruits)}
+inline: ${choice(["x", "y"])}
+bounded: ${rand(bounds)}
+in: ${contains(fruits, "banana")}
+`)
+	for seed := int64(1); seed <= 50; seed++ {
+		var result map[string]any
+		out, err := RenderJSON(yamlContent, Options{Seed: seed})
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(out, &result))
+
+		assert.Equal(t, float64(3), result["same"])
+		assert.Contains(t, []any{float64(1), float64(2)}, result["whole"])
+		assert.GreaterOrEqual(t, result["float"], 0.5)
+		assert.Less(t, result["float"], 1.5)
+		assert.Contains(t, []any{float64(1), float64(2), float64(3), float64(4), float64(5), float64(6)}, result["int"])
+		assert.GreaterOrEqual(t, result["unit"], float64(0))
+		assert.Less(t, result["unit"], float64(1))
+		assert.GreaterOrEqual(t, result["ranged"], float64(2))
+		assert.Less(t, result["ranged"], float64(3))
+		assert.Contains(t, []any{"apple", "banana", "cherry"}, result["anchor"])
+		assert.Contains(t, []any{"x", "y"}, result["inline"])
+		assert.Contains(t, []any{float64(5), float64(10)}, result["bounded"])
+		assert.Equal(t, true, result["in"])
+	}
+
+	for _, expression := range []string{"rand(2, 1)", "randint(1.5, 3)", "choice([])", `rand(1, "a")`} {
+		_, err := Eval([]byte("a: 1"), expression, Options{})
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestRandintBounds(t *testing.T) {
+	for _, expression := range []string{"randint(0, 10000000000000000000)", "rand(-9000000000000000000, 9000000000000000000)", "randint(-10000000000000000000, 0)"} {
+		_, err := RenderJSON([]byte("a: ${"+expression+"}"), Options{})
+		assert.ErrorContains(t, err, "randint function requires bounds less than 2^63 apart", expression)
+	}
+
+	out, err := RenderJSON([]byte("a: ${randint(4000000000000000000, 4000000000000000000)}"), Options{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":4000000000000000000}`, string(out))
+}
+
+func TestSampleCount(t *testing.T) {
+	for _, count := range []string{"-1", "4", "100000000000000000000"} {
+		_, err := RenderJSON([]byte("names: &names [a, b, c]\npicked: ${sample(names, "+count+")}"), Options{})
+		assert.ErrorContains(t, err, "sample function requires a count between 0 and 3", count)
+	}
+}
+
+func TestRandstrLength(t *testing.T) {
+	for _, length := range []string{"-1", "9000000000000000000"} {
+		_, err := RenderJSON([]byte("a: ${randstr("+length+")}"), Options{})
+		assert.ErrorContains(t, err, "randstr function requires a length between 0", length)
+	}
+
+	_, err := RenderJSON([]byte("a: ${randstr(100)}"), Options{Limits: Limits{MaxStringSize: 10}})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestFunctionsDocumented(t *testing.T) {
+	listed := Functions()
+	assert.Len(t, listed, len(functions))
+	for _, function := range listed {
+		assert.Contains(t, functionDocs, function.Name)
+		assert.True(t, strings.HasPrefix(function.Signature, function.Name+"("), function.Signature)
+	}
+	for name := range textFunctions {
+		assert.Contains(t, functions, name)
+	}
+}
@@ -2,11 +2,13 @@ module github.com/micah5/yamlx
 
 go 1.20
 
-require github.com/stretchr/testify v1.8.4
+require (
+	github.com/Knetic/govaluate v3.0.0+incompatible
+	github.com/stretchr/testify v1.8.4
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/Knetic/govaluate v3.0.0+incompatible // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,220 @@
+package yamlx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLimitExceeded is wrapped by the errors evaluation returns when a
+// template goes over one of its Limits.
+var ErrLimitExceeded = errors.New("yamlx: limit exceeded")
+
+// Limits bounds the work evaluating a template may do, for templates that
+// come from users you don't trust. Zero fields are unlimited.
+type Limits struct {
+	MaxIterations       int           // Loop iterations and range items, in total
+	MaxNodes            int           // Values in the output, counting each mapping, list and scalar
+	MaxDepth            int           // Nesting of mappings and lists
+	MaxExpressionLength int           // Length of a single ${} expression
+	MaxStringSize       int           // Length of any string a value or expression produces
+	Timeout             time.Duration // Time evaluation may take
+}
+
+// maxLength bounds the strings and lists functions build from a length
+// argument, whether or not Limits are set, so a huge length is an error
+// instead of an allocation that fails.
+const maxLength = 1 << 24
+
+// checkLength checks a length argument of the function name, which what
+// describes for the error.
+func checkLength(name string, what string, length float64) error {
+	if !(length >= 0 && length <= maxLength) {
+		return fmt.Errorf("%s function requires a %s between 0 and %d", name, what, maxLength)
+	}
+	return nil
+}
+
+// limiter tracks the work done by a parse against its limits.
+type limiter struct {
+	Limits
+	deadline   time.Time
+	iterations int
+	nodes      int
+	depth      int
+}
+
+func newLimiter(limits Limits) *limiter {
+	l := &limiter{Limits: limits}
+	if limits.Timeout > 0 {
+		l.deadline = time.Now().Add(limits.Timeout)
+	}
+	return l
+}
+
+func limitError(line int, format string, args ...any) error {
+	return fmt.Errorf("%w: %s (line %d)", ErrLimitExceeded, fmt.Sprintf(format, args...), line)
+}
+
+// iterate counts n loop iterations or range items.
+func (l *limiter) iterate(n int, line int) error {
+	l.iterations += n
+	if l.MaxIterations > 0 && l.iterations > l.MaxIterations {
+		return limitError(line, "more than %d loop iterations", l.MaxIterations)
+	}
+	return l.checkTime(line)
+}
+
+// addNodes counts n values added to the output.
+func (l *limiter) addNodes(n int, line int) error {
+	l.nodes += n
+	if l.MaxNodes > 0 && l.nodes > l.MaxNodes {
+		return limitError(line, "more than %d values", l.MaxNodes)
+	}
+	return l.checkTime(line)
+}
+
+// enter counts a level of nesting, which the caller undoes with leave.
+func (l *limiter) enter(line int) error {
+	l.depth++
+	if l.MaxDepth > 0 && l.depth > l.MaxDepth {
+		return limitError(line, "nested more than %d levels deep", l.MaxDepth)
+	}
+	return nil
+}
+
+func (l *limiter) leave() {
+	l.depth--
+}
+
+func (l *limiter) checkExpression(expression string, line int) error {
+	if l.MaxExpressionLength > 0 && len(expression) > l.MaxExpressionLength {
+		return limitError(line, "expression longer than %d characters", l.MaxExpressionLength)
+	}
+	return l.checkTime(line)
+}
+
+// checkString checks the size of a string a value or expression produced.
+func (l *limiter) checkString(value any, line int) error {
+	if s, ok := value.(string); ok {
+		return l.checkSize(len(s), line)
+	}
+	return nil
+}
+
+// checkSize checks the size in bytes of a string before it is built.
+func (l *limiter) checkSize(n int, line int) error {
+	if l.MaxStringSize > 0 && n > l.MaxStringSize {
+		return limitError(line, "string longer than %d bytes", l.MaxStringSize)
+	}
+	return nil
+}
+
+func (l *limiter) checkTime(line int) error {
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		return limitError(line, "evaluation took longer than %s", l.Timeout)
+	}
+	return nil
+}
+
+// countNodes counts the values in v, stopping once it passes max if max is
+// set, so aliasing a huge value costs no more than the limit.
+func countNodes(v any, max int) int {
+	count := 1
+	switch v := v.(type) {
+	case []any:
+		for _, elem := range v {
+			if max > 0 && count > max {
+				break
+			}
+			count += countNodes(elem, max)
+		}
+	case *OrderedMap:
+		for _, k := range v.keys {
+			if max > 0 && count > max {
+				break
+			}
+			count += countNodes(v.values[k], max)
+		}
+	case map[string]any:
+		for _, elem := range v {
+			if max > 0 && count > max {
+				break
+			}
+			count += countNodes(elem, max)
+		}
+	}
+	return count
+}
+
+// loopRange is the values a loop or range runs over, produced on demand so
+// that large ranges aren't built before they can be checked against limits.
+type loopRange struct {
+	len int
+	at  func(i int) any
+}
+
+// parseRange reads a range: an alias of a list like *environments, an
+// integer range like 1..5, or comma separated values.
+func (p *parser) parseRange(rangeString string) (loopRange, error) {
+	rangeString = strings.TrimSpace(rangeString)
+	switch {
+	case strings.HasPrefix(rangeString, "*"):
+		name := strings.TrimSpace(rangeString[1:])
+		list, ok := p.lookup(name).([]any)
+		if !ok {
+			return loopRange{}, fmt.Errorf("anchor %q is not a list", name)
+		}
+		return loopRange{len(list), func(i int) any { return list[i] }}, nil
+	case strings.Count(rangeString, "..") == 1:
+		startText, endText, _ := strings.Cut(rangeString, "..")
+		start, err := strconv.ParseInt(strings.TrimSpace(startText), 10, 64)
+		if err != nil {
+			return loopRange{}, fmt.Errorf("invalid range start: %s", rangeString)
+		}
+		end, err := strconv.ParseInt(strings.TrimSpace(endText), 10, 64)
+		if err != nil {
+			return loopRange{}, fmt.Errorf("invalid range end: %s", rangeString)
+		}
+		n := 0
+		if end >= start {
+			// Measured in uint64, as the distance between two int64s may not fit
+			if uint64(end)-uint64(start) >= uint64(^uint(0)>>1) {
+				return loopRange{}, fmt.Errorf("range too large: %s", rangeString)
+			}
+			n = int(end-start) + 1
+		}
+		return loopRange{n, func(i int) any { return start + int64(i) }}, nil
+	default:
+		parts := strings.Split(rangeString, ",")
+		values := make([]any, len(parts))
+		for i, part := range parts {
+			values[i] = strings.TrimSpace(part)
+		}
+		return loopRange{len(values), func(i int) any { return values[i] }}, nil
+	}
+}
+
+// expandRange builds the list a range value like [1..5] stands for.
+func (p *parser) expandRange(rangeString string, line int) ([]any, error) {
+	r, err := p.parseRange(rangeString)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.limits.iterate(r.len, line); err != nil {
+		return nil, err
+	}
+	if err := p.limits.addNodes(r.len, line); err != nil {
+		return nil, err
+	}
+	if r.len > maxLength {
+		return nil, fmt.Errorf("range too large: %s has more than %d items", rangeString, maxLength)
+	}
+	list := make([]any, r.len)
+	for i := range list {
+		list[i] = r.at(i)
+	}
+	return list, nil
+}
@@ -0,0 +1,62 @@
+package yamlx
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		limits   Limits
+		message  string
+	}{
+		{"range", "numbers: [1..1000000000]", Limits{MaxIterations: 1000}, "more than 1000 loop iterations (line 1)"},
+		{"loop", "items:\n  !for i in [1..1000000000]:\n    - ${i}", Limits{MaxIterations: 1000}, "more than 1000 loop iterations (line 3)"},
+		{"nested loops", "envs: &envs [a, b, c]\nitems:\n  !for i in [1..10]:\n    - nested:\n      !for e in *envs:\n        - ${e}", Limits{MaxIterations: 20}, "more than 20 loop iterations (line 4)"},
+		{"nodes", "items:\n  !for i in [1..100]:\n    - ${i}", Limits{MaxNodes: 50}, "more than 50 values (line 3)"},
+		{"aliases", "big: &big [1..40]\ncopy: *big", Limits{MaxNodes: 50}, "more than 50 values (line 2)"},
+		{"depth", "a:\n  b:\n    c:\n      d: 1", Limits{MaxDepth: 2}, "nested more than 2 levels deep (line 4)"},
+		{"expression", "a: ${1 + 2 + 3}", Limits{MaxExpressionLength: 5}, "expression longer than 5 characters (line 1)"},
+		{"string", `a: ${"abc" + "def"}`, Limits{MaxStringSize: 4}, "string longer than 4 bytes (line 1)"},
+		{"timeout", "items:\n  !for i in [1..1000000000]:\n    - ${i}", Limits{Timeout: 10 * time.Millisecond}, "evaluation took longer than 10ms (line 3)"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := RenderJSON([]byte(c.template), Options{Limits: c.limits})
+			assert.True(t, errors.Is(err, ErrLimitExceeded), "%v", err)
+			assert.True(t, strings.HasSuffix(err.Error(), c.message), err.Error())
+
+			// The same template is fine with no limits, except the huge ones
+			if !strings.Contains(c.template, "1000000000") {
+				_, err = RenderJSON([]byte(c.template), Options{})
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestLoopErrorsPropagate(t *testing.T) {
+	yamlContent := `
+items:
+  !for i in [1..3]:
+    - ${i +}
+`
+	_, err := RenderJSON([]byte(yamlContent), Options{})
+	assert.Error(t, err)
+}
+
+func TestRangeValueSize(t *testing.T) {
+	for _, template := range []string{"a: [1..9000000000000000000]", "a: [1..1000000000]", "a: [-9000000000000000000..9000000000000000000]"} {
+		_, err := RenderJSON([]byte(template), Options{})
+		assert.ErrorContains(t, err, "range too large", template)
+	}
+
+	out, err := RenderJSON([]byte("a: [9223372036854775806..9223372036854775807]"), Options{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":[9223372036854775806,9223372036854775807]}`, string(out))
+}
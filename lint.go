@@ -0,0 +1,294 @@
+package yamlx
+
+import (
+	"fmt"
+	"github.com/Knetic/govaluate"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severity is how serious a lint finding is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a single problem found by Lint.
+type Diagnostic struct {
+	Rule     string   `json:"rule"` // ID of the rule that found it, e.g. "undefined-alias"
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"`   // 1-based
+	Column   int      `json:"column"` // 1-based
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", d.Line, d.Column, d.Severity, d.Message, d.Rule)
+}
+
+// LintRule describes a check Lint performs.
+type LintRule struct {
+	ID          string
+	Description string
+}
+
+var lintRules = []LintRule{
+	{"syntax", "The file can't be tokenized."},
+	{"tabs", "Tabs are used for indentation, which yamlx doesn't understand."},
+	{"indentation", "A line isn't indented by the same width as the rest of the file."},
+	{"duplicate-key", "A key appears more than once in the same mapping."},
+	{"undefined-alias", "An alias refers to an anchor that isn't defined before it."},
+	{"unused-anchor", "An anchor is never referred to by an alias, merge key, loop or expression."},
+	{"shadowed-variable", "A loop variable has the same name as an anchor or an enclosing loop variable."},
+	{"invalid-expression", "An expression doesn't compile."},
+	{"nondeterministic", "An expression calls a random function, whose result changes whenever the document or seed does."},
+}
+
+// LintRules returns the rules Lint checks.
+func LintRules() []LintRule {
+	return append([]LintRule(nil), lintRules...)
+}
+
+// nondeterministicFunctions are the functions the nondeterministic rule flags.
+var nondeterministicFunctions = map[string]bool{
+	"rand":    true,
+	"randint": true,
+	"randf":   true,
+	"choice":  true,
+	"shuffle": true,
+	"sample":  true,
+	"randstr": true,
+}
+
+// Lint checks yamlx source for problems without evaluating it, returning
+// what it finds ordered by position.
+func Lint(data []byte) []Diagnostic {
+	l := &linter{lines: strings.Split(string(data), "\n"), anchors: make(map[string]*lintAnchor)}
+	l.checkTabs()
+
+	if _, err := Tokenize(l.lines, 0); err != nil {
+		l.report("syntax", SeverityError, 1, 1, err.Error())
+	}
+	doc, err := ParseDocument(data)
+	if err != nil {
+		l.sort()
+		return l.diagnostics
+	}
+
+	l.checkIndentation(doc.Nodes, -1)
+	l.checkDuplicateKeys(doc.Nodes)
+	l.walk(doc.Nodes)
+	for _, anchor := range l.order {
+		if !anchor.used {
+			l.report("unused-anchor", SeverityWarning, anchor.node.Line, l.column(anchor.node, "&"+anchor.name),
+				fmt.Sprintf("anchor %q is never used", anchor.name))
+		}
+	}
+	l.sort()
+	return l.diagnostics
+}
+
+// linter holds the state of a single Lint call.
+type linter struct {
+	lines       []string
+	diagnostics []Diagnostic
+	anchors     map[string]*lintAnchor // Anchors defined so far, by name
+	order       []*lintAnchor          // Every anchor definition, in order
+	scopes      [][]string             // Variables of the loops around the current node
+	step        int                    // Indentation width of the file, once known
+}
+
+type lintAnchor struct {
+	name string
+	node *Node
+	used bool
+}
+
+func (l *linter) report(rule string, severity Severity, line int, column int, message string) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{rule, severity, line, column, message})
+}
+
+func (l *linter) sort() {
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// column returns the 1-based column of text on a node's line, or of the
+// start of the line if the text isn't written there as is.
+func (l *linter) column(node *Node, text string) int {
+	if node.Line == 0 || node.Line > len(l.lines) {
+		return 1
+	}
+	index := strings.Index(l.lines[node.Line-1], text)
+	if index < 0 {
+		return node.Indent + 1
+	}
+	return index + 1
+}
+
+func (l *linter) checkTabs() {
+	for i, line := range l.lines {
+		indentation := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if index := strings.Index(indentation, "\t"); index >= 0 {
+			l.report("tabs", SeverityError, i+1, index+1, "tab used for indentation")
+		}
+	}
+}
+
+// checkIndentation checks that every level is indented by the same width as
+// the first nested line in the file.
+func (l *linter) checkIndentation(nodes []*Node, parentIndent int) {
+	for _, node := range nodes {
+		if parentIndent < 0 {
+			if node.Indent != 0 {
+				l.report("indentation", SeverityWarning, node.Line, 1,
+					fmt.Sprintf("top level line is indented by %d spaces", node.Indent))
+			}
+		} else {
+			width := node.Indent - parentIndent
+			if l.step == 0 {
+				l.step = width
+			} else if width != l.step {
+				l.report("indentation", SeverityWarning, node.Line, 1,
+					fmt.Sprintf("expected indentation of %d spaces, found %d", l.step, width))
+			}
+		}
+		l.checkIndentation(node.Children, node.Indent)
+	}
+}
+
+// checkDuplicateKeys reports keys repeated within the same mapping.
+func (l *linter) checkDuplicateKeys(nodes []*Node) {
+	seen := make(map[string]*Node)
+	for _, node := range nodes {
+		if node.Type == KEY {
+			if first, ok := seen[node.Key]; ok {
+				l.report("duplicate-key", SeverityError, node.Line, node.Indent+1,
+					fmt.Sprintf("duplicate key %q (first defined on line %d)", node.Key, first.Line))
+			} else {
+				seen[node.Key] = node
+			}
+		}
+		if node.Type == LIST_ITEM && node.Key != "" {
+			// "- key: value" items form a mapping with their children
+			l.checkDuplicateKeys(append([]*Node{{Type: KEY, Key: node.Key, Line: node.Line}}, node.Children...))
+			for _, child := range node.Children {
+				l.checkDuplicateKeys(child.Children)
+			}
+			continue
+		}
+		l.checkDuplicateKeys(node.Children)
+	}
+}
+
+// walk checks anchors, aliases, loops and expressions in document order.
+func (l *linter) walk(nodes []*Node) {
+	for _, node := range nodes {
+		if node.Type == LOOP {
+			l.walkLoop(node)
+			continue
+		}
+		if node.Alias != "" {
+			l.use(node, node.Alias, "*"+node.Alias)
+		}
+		l.checkExpressions(node, node.Value)
+		l.walk(node.Children)
+		if node.Anchor != "" {
+			anchor := &lintAnchor{name: node.Anchor, node: node}
+			l.anchors[node.Anchor] = anchor
+			l.order = append(l.order, anchor)
+		}
+	}
+}
+
+func (l *linter) walkLoop(node *Node) {
+	if strings.HasPrefix(node.Value, "*") {
+		l.use(node, strings.TrimSpace(node.Value[1:]), node.Value)
+	}
+	var variables []string
+	for _, variable := range strings.Split(node.Key, ",") {
+		variable = strings.TrimSpace(variable)
+		variables = append(variables, variable)
+		if anchor, ok := l.anchors[variable]; ok {
+			l.report("shadowed-variable", SeverityWarning, node.Line, l.column(node, variable),
+				fmt.Sprintf("loop variable %q shadows the anchor defined on line %d", variable, anchor.node.Line))
+		} else if l.inScope(variable) {
+			l.report("shadowed-variable", SeverityWarning, node.Line, l.column(node, variable),
+				fmt.Sprintf("loop variable %q shadows the variable of an enclosing loop", variable))
+		}
+	}
+	l.scopes = append(l.scopes, variables)
+	l.walk(node.Children)
+	l.scopes = l.scopes[:len(l.scopes)-1]
+}
+
+func (l *linter) inScope(name string) bool {
+	for _, scope := range l.scopes {
+		if containsString(scope, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// use resolves an alias, which may reach into a mapping like anchor.key.
+func (l *linter) use(node *Node, name string, text string) {
+	base, _, _ := strings.Cut(name, ".")
+	if l.inScope(base) {
+		return
+	}
+	if anchor, ok := l.anchors[base]; ok {
+		anchor.used = true
+		return
+	}
+	l.report("undefined-alias", SeverityError, node.Line, l.column(node, text),
+		fmt.Sprintf("alias %q refers to an undefined anchor", name))
+}
+
+var (
+	expressionRegex = regexp.MustCompile(`\$\{([^\}]+)\}`)
+	stringRegex     = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+	identifierRegex = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_.]*(\s*\()?`)
+)
+
+// checkExpressions compiles the expressions in a value and records the
+// anchors they refer to.
+func (l *linter) checkExpressions(node *Node, value string) {
+	for _, match := range expressionRegex.FindAllStringSubmatchIndex(value, -1) {
+		expression := value[match[2]:match[3]]
+		column := l.column(node, value[match[0]:match[1]])
+		wrapped, _ := listLiterals(expression)
+		wrapped = wrapAnchors(wrapped, func(name string) bool {
+			// Dotted names reach into an anchor or loop variable
+			base, _, dotted := strings.Cut(name, ".")
+			_, isAnchor := l.anchors[name]
+			return isAnchor || (dotted && (l.anchors[base] != nil || l.inScope(base)))
+		})
+		if _, err := govaluate.NewEvaluableExpressionWithFunctions(wrapped, functions); err != nil {
+			l.report("invalid-expression", SeverityError, node.Line, column,
+				fmt.Sprintf("invalid expression %q: %v", expression, err))
+		}
+		for _, identifier := range identifierRegex.FindAllString(stringRegex.ReplaceAllString(expression, `""`), -1) {
+			if strings.HasSuffix(identifier, "(") {
+				name := strings.TrimSpace(strings.TrimSuffix(identifier, "("))
+				if nondeterministicFunctions[name] {
+					l.report("nondeterministic", SeverityWarning, node.Line, column,
+						fmt.Sprintf("%s() gives a different result whenever the document or seed changes", name))
+				}
+				continue
+			}
+			base, _, _ := strings.Cut(identifier, ".")
+			if anchor, ok := l.anchors[base]; ok {
+				anchor.used = true
+			}
+		}
+	}
+}
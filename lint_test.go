@@ -0,0 +1,57 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	yamlContent := `environments: &environments [dev, prod]
+unused: &unused 1
+name: &name web
+servers:
+  !for name in *environments:
+    - host: ${name}.example.com
+      port: ${rand(1000, 2000)}
+      port: 22
+      size: ${len(}
+      db: *database
+nested:
+    key: *name
+	tabbed: 1
+`
+	diagnostics := Lint([]byte(yamlContent))
+
+	assert.Equal(t, []Diagnostic{
+		{"unused-anchor", SeverityWarning, 2, 9, `anchor "unused" is never used`},
+		{"shadowed-variable", SeverityWarning, 5, 8, `loop variable "name" shadows the anchor defined on line 3`},
+		{"nondeterministic", SeverityWarning, 7, 13, "rand() gives a different result whenever the document or seed changes"},
+		{"duplicate-key", SeverityError, 8, 7, `duplicate key "port" (first defined on line 7)`},
+		{"invalid-expression", SeverityError, 9, 13, `invalid expression "len(": Unbalanced parenthesis`},
+		{"undefined-alias", SeverityError, 10, 11, `alias "database" refers to an undefined anchor`},
+		{"indentation", SeverityWarning, 12, 1, "expected indentation of 2 spaces, found 4"},
+		{"tabs", SeverityError, 13, 1, "tab used for indentation"},
+	}, diagnostics)
+}
+
+func TestLintClean(t *testing.T) {
+	yamlContent := `defaults: &defaults
+  port: 22
+base: &base
+  host: example.com
+servers:
+  !for idx, env in [dev, prod]:
+    - name: ${env}-${idx}
+      host: ${base.host}
+custom:
+  <<: *defaults
+`
+	assert.Empty(t, Lint([]byte(yamlContent)))
+}
+
+func TestLintColumnFallback(t *testing.T) {
+	diagnostics := Lint([]byte("nested:\n  db: * database\n"))
+	assert.Equal(t, []Diagnostic{
+		{"undefined-alias", SeverityError, 2, 3, `alias "database" refers to an undefined anchor`},
+	}, diagnostics)
+}
@@ -0,0 +1,192 @@
+package yamlx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// List is how a list reaches the functions expressions call. govaluate
+// spreads a []any argument into separate arguments, and merges it with the
+// arguments after it, so lists are passed under a type of their own to
+// arrive whole.
+type List []any
+
+// spread returns the items of a lone List argument, or the arguments
+// themselves, for functions that take either a list or several values.
+func spread(args []any) []any {
+	if len(args) == 1 {
+		if list, ok := args[0].(List); ok {
+			return list
+		}
+	}
+	return args
+}
+
+// plainList converts the Lists in a value back to []any.
+func plainList(value any) any {
+	switch v := value.(type) {
+	case List:
+		return plainList([]any(v))
+	case []any:
+		l := make([]any, len(v))
+		for i, elem := range v {
+			l[i] = plainList(elem)
+		}
+		return l
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, elem := range v {
+			m[k] = plainList(elem)
+		}
+		return m
+	}
+	return value
+}
+
+// listItems returns the items of a List or []any.
+func listItems(value any) ([]any, bool) {
+	switch value := value.(type) {
+	case List:
+		return value, true
+	case []any:
+		return value, true
+	}
+	return nil, false
+}
+
+// equalValues compares two values from an expression, treating numbers of
+// different types as equal when they have the same value.
+func equalValues(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return a == b
+}
+
+// toFloat converts any of the number types an expression can see.
+func toFloat(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case int64:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	}
+	return 0, false
+}
+
+// expressionParameters are the values an expression can refer to: the
+// anchors, plus the list literals written in it. Lists are handed out as
+// Lists.
+type expressionParameters struct {
+	anchors map[string]any
+	lists   map[string]any
+}
+
+func (p expressionParameters) Get(name string) (any, error) {
+	value, ok := p.lists[name]
+	if !ok {
+		value, ok = p.anchors[name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("No parameter '%s' found.", name)
+	}
+	if list, ok := value.([]any); ok {
+		return List(list), nil
+	}
+	return value, nil
+}
+
+// listLiterals replaces the [a, b] list literals in an expression, which
+// govaluate can't read, with parameter names. It returns the elements of
+// each list by the name that replaced it.
+func listLiterals(expression string) (string, map[string][]string) {
+	var b strings.Builder
+	var lists map[string][]string
+	for i := 0; i < len(expression); i++ {
+		end := i
+		switch expression[i] {
+		case '"', '\'':
+			end = -1
+			if j := strings.IndexByte(expression[i+1:], expression[i]); j >= 0 {
+				end = i + 1 + j
+			}
+		case '[':
+			end = closingBracket(expression, i)
+		}
+		if end < i {
+			// Unterminated, which govaluate will report
+			b.WriteString(expression[i:])
+			break
+		}
+		if expression[i] != '[' {
+			b.WriteString(expression[i : end+1])
+			i = end
+			continue
+		}
+		if lists == nil {
+			lists = make(map[string][]string)
+		}
+		name := fmt.Sprintf("yamlx_list%d", len(lists))
+		lists[name] = splitElements(expression[i+1 : end])
+		b.WriteString(name)
+		i = end
+	}
+	return b.String(), lists
+}
+
+// closingBracket returns the index of the bracket closing the one at start,
+// or -1 if it isn't closed.
+func closingBracket(expression string, start int) int {
+	depth := 0
+	var quote byte
+	for i := start; i < len(expression); i++ {
+		switch ch := expression[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '(':
+			depth++
+		case ch == ']' || ch == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitElements splits the contents of a list literal on its top-level
+// commas.
+func splitElements(contents string) []string {
+	if strings.TrimSpace(contents) == "" {
+		return nil
+	}
+	var elements []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(contents); i++ {
+		switch ch := contents[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '(':
+			depth++
+		case ch == ']' || ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			elements = append(elements, strings.TrimSpace(contents[start:i]))
+			start = i + 1
+		}
+	}
+	return append(elements, strings.TrimSpace(contents[start:]))
+}
@@ -1,89 +1,204 @@
 package yamlx
 
 import (
+	"context"
+	"encoding"
 	"errors"
+	"fmt"
+	"github.com/Knetic/govaluate"
 	"gopkg.in/yaml.v3"
 	"reflect"
+	"sort"
 	"strings"
 )
 
 // Unmarshals YAMLX data into a Go struct
 func Unmarshal(data []byte, v interface{}) error {
-	lines := strings.Split(string(data), "\n")
-	tokens, err := Tokenize(lines, 0)
+	return UnmarshalWithOptions(data, v, Options{})
+}
+
+// UnmarshalWithOptions unmarshals YAMLX data into a Go struct using the given options
+func UnmarshalWithOptions(data []byte, v interface{}, opts Options) error {
+	return UnmarshalContext(context.Background(), data, v, opts)
+}
+
+// UnmarshalContext is like UnmarshalWithOptions but stops evaluating when ctx
+// is done, returning ctx.Err() along with the line it got to.
+func UnmarshalContext(ctx context.Context, data []byte, v interface{}, opts Options) error {
+	t, err := Compile(data, opts)
 	if err != nil {
 		return err
 	}
+	return t.ExecuteContext(ctx, v)
+}
+
+// evaluate tokenizes and parses YAMLX data, returning the parser along with
+// the result so callers can reach the anchors and sources it collected.
+func evaluate(data []byte, opts Options) (*OrderedMap, *parser, error) {
+	t, err := Compile(data, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t.evaluate(context.Background())
+}
 
-	parsedData, err := Parse(tokens)
+// Eval evaluates a single expression, written without the surrounding ${},
+// against the anchors YAMLX data defines.
+func Eval(data []byte, expression string, opts Options) (any, error) {
+	_, p, err := evaluate(data, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return evaluateWithFunctions(expression, p.anchors, p.expressionFunctions())
+}
 
-	return mapToStruct(parsedData, v)
+// Anchors returns the anchors YAMLX data defines, as expressions see them.
+// Anchors holding a mapping also appear flattened under dotted names, e.g.
+// db.port for the port key of the db anchor.
+func Anchors(data []byte, opts Options) (map[string]any, error) {
+	_, p, err := evaluate(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return p.anchors, nil
 }
 
 // Marshal just returns the data as yaml
 func Marshal(v interface{}) ([]byte, error) {
-	noTagType := createModifiedTagType(reflect.TypeOf(v))
-	noTagValue := reflect.New(noTagType).Elem()
-	copyToModifiedTagStruct(reflect.ValueOf(v), noTagValue)
-	newVal := noTagValue.Interface()
-	return yaml.Marshal(newVal)
+	node, err := marshalNode(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(node)
 }
 
-// createModifiedTagType creates a parallel type for the given type with modified tags.
-func createModifiedTagType(t reflect.Type) reflect.Type {
-	switch t.Kind() {
+// marshalNode builds the yaml node for a value, reading yamlx struct tags.
+func marshalNode(val reflect.Value) (*yaml.Node, error) {
+	if !val.IsValid() {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	if val.CanInterface() {
+		switch val.Interface().(type) {
+		case yaml.Marshaler, encoding.TextMarshaler:
+			// Types that know how to encode themselves, e.g. time.Time
+			node := &yaml.Node{}
+			return node, node.Encode(val.Interface())
+		}
+	}
+
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return marshalNode(reflect.Value{})
+		}
+		return marshalNode(val.Elem())
+
 	case reflect.Struct:
-		fields := make([]reflect.StructField, t.NumField())
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-			tag := string(field.Tag)
-			modifiedTag := strings.Replace(tag, "yamlx", "yaml", -1)
-			fields[i] = reflect.StructField{
-				Name: field.Name,
-				Type: createModifiedTagType(field.Type),
-				Tag:  reflect.StructTag(modifiedTag),
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		typ := val.Type()
+		for i := 0; i < val.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			tag := parseFieldTag(field)
+			name, _, _ := strings.Cut(field.Tag.Get("yamlx"), ",")
+			if field.Tag.Get("yamlx") == "" {
+				// Fields without a yamlx tag follow their yaml tag, if any
+				parts := strings.Split(field.Tag.Get("yaml"), ",")
+				name, tag.Options = parts[0], parts[1:]
+			}
+			if name == "-" {
+				continue
+			}
+			tag.Name = name
+			if name == "" {
+				// Unnamed fields use yaml's lowercase convention
+				tag.Name = strings.ToLower(field.Name)
+			}
+			fieldVal := val.Field(i)
+			if tag.has("omitempty") && isEmptyValue(fieldVal) {
+				continue
 			}
+			valueNode, err := marshalNode(fieldVal)
+			if err != nil {
+				return nil, err
+			}
+			if tag.has("flow") {
+				valueNode.Style |= yaml.FlowStyle
+			}
+			if tag.has("inline") || (field.Anonymous && field.Tag.Get("yamlx") == "") {
+				if valueNode.Kind == yaml.MappingNode {
+					node.Content = append(node.Content, valueNode.Content...)
+					continue
+				}
+				if valueNode.Tag == "!!null" {
+					continue
+				}
+				return nil, fmt.Errorf("yamlx: inline field %s must be a struct or map", field.Name)
+			}
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: tag.Name}
+			node.Content = append(node.Content, keyNode, valueNode)
 		}
-		return reflect.StructOf(fields)
+		return node, nil
 
-	case reflect.Slice:
-		elemType := createModifiedTagType(t.Elem())
-		return reflect.SliceOf(elemType)
+	case reflect.Map:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		keys := val.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return lessKey(keys[i], keys[j])
+		})
+		for _, key := range keys {
+			keyNode, err := marshalNode(key)
+			if err != nil {
+				return nil, err
+			}
+			valueNode, err := marshalNode(val.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+		return node, nil
 
-	default:
-		return t
-	}
-}
-
-// copyToModifiedTagStruct copies data from the original struct to the modified tag struct.
-func copyToModifiedTagStruct(src, dest reflect.Value) {
-	for i := 0; i < src.NumField(); i++ {
-		srcField := src.Field(i)
-		destField := dest.Field(i)
-
-		if srcField.Kind() == reflect.Struct {
-			newDestField := reflect.New(destField.Type()).Elem()
-			copyToModifiedTagStruct(srcField, newDestField)
-			destField.Set(newDestField)
-		} else if srcField.Kind() == reflect.Slice {
-			newSlice := reflect.MakeSlice(destField.Type(), srcField.Len(), srcField.Cap())
-			for j := 0; j < srcField.Len(); j++ {
-				newElem := reflect.New(destField.Type().Elem()).Elem()
-				copyToModifiedTagStruct(srcField.Index(j), newElem)
-				newSlice.Index(j).Set(newElem)
+	case reflect.Slice, reflect.Array:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for i := 0; i < val.Len(); i++ {
+			elemNode, err := marshalNode(val.Index(i))
+			if err != nil {
+				return nil, err
 			}
-			destField.Set(newSlice)
-		} else {
-			destField.Set(srcField)
+			node.Content = append(node.Content, elemNode)
 		}
+		return node, nil
+
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil, fmt.Errorf("yamlx: cannot marshal value of type %s", val.Type())
+
+	default:
+		node := &yaml.Node{}
+		return node, node.Encode(val.Interface())
+	}
+}
+
+// isEmptyValue reports whether a value is left out by omitempty.
+func isEmptyValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return val.Len() == 0
 	}
+	return val.IsZero()
+}
+
+// decoder holds the state used while mapping parsed data onto Go values.
+type decoder struct {
+	opts      Options
+	anchors   map[string]any // Anchors defined by the document, used to evaluate defaults
+	functions map[string]govaluate.ExpressionFunction
 }
 
 // mapToStruct maps a generic map[string]any to a struct
-func mapToStruct(m map[string]any, s interface{}) error {
+func (d *decoder) mapToStruct(m map[string]any, s interface{}, path string) error {
 	val := reflect.ValueOf(s)
 	if val.Kind() != reflect.Ptr || val.IsNil() {
 		return errors.New("yamlx: Unmarshal requires a non-nil pointer to a struct")
@@ -95,24 +210,53 @@ func mapToStruct(m map[string]any, s interface{}) error {
 	}
 
 	typ := val.Type()
+	known := make([]string, 0, val.NumField())
 	for i := 0; i < val.NumField(); i++ {
-		field := typ.Field(i)
-		tag := field.Tag.Get("yamlx")
-		if tag != "" {
-			tagParts := strings.Split(tag, ",")
-			tag = tagParts[0] // Only use the first part of the tag
-		} else {
-			tag = field.Name
+		tag := parseFieldTag(typ.Field(i))
+		known = append(known, tag.Name)
+
+		fieldVal := val.Field(i)
+		if !fieldVal.IsValid() || !fieldVal.CanSet() {
+			continue
 		}
 
-		value, ok := m[tag]
+		fieldPath := joinPath(path, tag.Name)
+		value, ok := m[tag.Name]
 		if !ok {
-			continue
+			if tag.HasDefault {
+				defaultValue, err := parseValue(tag.Default, d.anchors, d.functions)
+				if err != nil {
+					return fmt.Errorf("yamlx: invalid default for %q: %w", fieldPath, err)
+				}
+				value = defaultValue
+			} else if tag.Required && m != nil {
+				return fmt.Errorf("yamlx: missing required key %q", fieldPath)
+			} else if fieldVal.Kind() == reflect.Struct {
+				// Apply the defaults of a nested struct even when its key is absent
+				if err := d.mapToStruct(nil, fieldVal.Addr().Interface(), fieldPath); err != nil {
+					return err
+				}
+				continue
+			} else {
+				continue
+			}
 		}
 
-		fieldVal := val.Field(i)
-		if fieldVal.IsValid() && fieldVal.CanSet() {
-			setField(value, fieldVal)
+		if err := d.setField(value, fieldVal, fieldPath); err != nil {
+			return err
+		}
+	}
+
+	if d.opts.Strict {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !containsString(known, k) {
+				return unknownKeyError(path, k, known)
+			}
 		}
 	}
 
@@ -120,9 +264,9 @@ func mapToStruct(m map[string]any, s interface{}) error {
 }
 
 // setField sets a field of a struct based on its type
-func setField(value any, fieldVal reflect.Value) {
+func (d *decoder) setField(value any, fieldVal reflect.Value, path string) error {
 	if value == nil {
-		return
+		return nil
 	}
 
 	switch fieldVal.Kind() {
@@ -133,6 +277,8 @@ func setField(value any, fieldVal reflect.Value) {
 	case reflect.Float32, reflect.Float64:
 		if val, ok := value.(float64); ok {
 			fieldVal.SetFloat(val)
+		} else if val, ok := value.(int64); ok {
+			fieldVal.SetFloat(float64(val))
 		}
 	case reflect.String:
 		if val, ok := value.(string); ok {
@@ -146,7 +292,9 @@ func setField(value any, fieldVal reflect.Value) {
 		if val, ok := value.([]any); ok {
 			slice := reflect.MakeSlice(fieldVal.Type(), len(val), len(val))
 			for i := 0; i < len(val); i++ {
-				setField(val[i], slice.Index(i))
+				if err := d.setField(val[i], slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
 			}
 			fieldVal.Set(slice)
 		}
@@ -155,14 +303,111 @@ func setField(value any, fieldVal reflect.Value) {
 			m := reflect.MakeMap(fieldVal.Type())
 			for k, v := range val {
 				mapVal := reflect.New(fieldVal.Type().Elem()).Elem()
-				setField(v, mapVal)
+				if err := d.setField(v, mapVal, joinPath(path, k)); err != nil {
+					return err
+				}
 				m.SetMapIndex(reflect.ValueOf(k), mapVal)
 			}
 			fieldVal.Set(m)
 		}
 	case reflect.Struct:
 		if val, ok := value.(map[string]any); ok {
-			mapToStruct(val, fieldVal.Addr().Interface())
+			return d.mapToStruct(val, fieldVal.Addr().Interface(), path)
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldTag holds the parsed contents of a yamlx struct tag.
+type fieldTag struct {
+	Name       string
+	Default    string
+	HasDefault bool
+	Required   bool
+	Options    []string // Any other options, e.g. omitempty
+}
+
+// has reports whether the tag has an option like omitempty.
+func (t fieldTag) has(option string) bool {
+	return containsString(t.Options, option)
+}
+
+// lessKey orders map keys, numbers by value and anything else by how it
+// prints.
+func lessKey(a, b reflect.Value) bool {
+	x, ok := numericKey(a)
+	y, ok2 := numericKey(b)
+	if ok && ok2 {
+		return x < y
+	}
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+func numericKey(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Interface:
+		if !v.IsNil() {
+			return numericKey(v.Elem())
+		}
+	}
+	return 0, false
+}
+
+// parseFieldTag reads the yamlx tag of a struct field, e.g.
+// `yamlx:"port,default=22"` or `yamlx:"name,required"`.
+func parseFieldTag(field reflect.StructField) fieldTag {
+	tag := fieldTag{Name: field.Name}
+	parts := splitTag(field.Tag.Get("yamlx"))
+	if len(parts) > 0 && parts[0] != "" {
+		tag.Name = parts[0]
+	}
+	for _, part := range parts[1:] {
+		switch {
+		case part == "required":
+			tag.Required = true
+		case strings.HasPrefix(part, "default="):
+			tag.Default = strings.TrimPrefix(part, "default=")
+			tag.HasDefault = true
+		default:
+			tag.Options = append(tag.Options, part)
+		}
+	}
+	return tag
+}
+
+// splitTag splits a tag on commas that aren't inside an expression, quotes or
+// brackets, so defaults like `default=${join(",", hosts)}` stay whole.
+func splitTag(tag string) []string {
+	var parts []string
+	depth, inQuotes, start := 0, false, 0
+	for i, ch := range tag {
+		switch {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case ch == '{' || ch == '(' || ch == '[':
+			depth++
+		case ch == '}' || ch == ')' || ch == ']':
+			depth--
+		case ch == ',' && depth == 0:
+			parts = append(parts, tag[start:i])
+			start = i + 1
 		}
 	}
+	return append(parts, tag[start:])
 }
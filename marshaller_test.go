@@ -114,3 +114,190 @@ existingField: value
 	assert.NoError(t, err)
 	assert.Equal(t, expected, result)
 }
+
+type StrictServer struct {
+	Name string `yamlx:"name"`
+	Port int    `yamlx:"port"`
+}
+
+type StrictStruct struct {
+	Servers []StrictServer `yamlx:"servers"`
+}
+
+func TestUnmarshalStrictUnknownKey(t *testing.T) {
+	yamlContent := `
+servers:
+  - name: web
+    prot: 22
+`
+	var result StrictStruct
+	err := Unmarshal([]byte(yamlContent), &result)
+	assert.NoError(t, err)
+
+	err = UnmarshalWithOptions([]byte(yamlContent), &result, Options{Strict: true})
+	assert.EqualError(t, err, `yamlx: unknown key "servers[0].prot", did you mean "port"?`)
+}
+
+func TestUnmarshalStrictDuplicateKey(t *testing.T) {
+	yamlContent := `
+servers:
+  - name: web
+    port: 22
+    name: db
+`
+	var result StrictStruct
+	err := UnmarshalWithOptions([]byte(yamlContent), &result, Options{Strict: true})
+	assert.EqualError(t, err, `yamlx: duplicate key "servers[0].name" on line 5 (first defined on line 3)`)
+}
+
+type DefaultsStruct struct {
+	Name     string  `yamlx:"name,required"`
+	Port     int     `yamlx:"port,default=22"`
+	Ratio    float64 `yamlx:"ratio,default=1"`
+	Host     string  `yamlx:"host,default=${name}.${join(\".\", domains)}"`
+	Database struct {
+		Engine string `yamlx:"engine,default=mysql"`
+	} `yamlx:"database"`
+}
+
+func TestUnmarshalDefaults(t *testing.T) {
+	yamlContent := `
+domains: &domains [example, com]
+name: &name web
+`
+	var result DefaultsStruct
+	err := Unmarshal([]byte(yamlContent), &result)
+
+	expected := DefaultsStruct{
+		Name:  "web",
+		Port:  22,
+		Ratio: 1,
+		Host:  "web.example.com",
+	}
+	expected.Database.Engine = "mysql"
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func TestUnmarshalRequired(t *testing.T) {
+	yamlContent := `
+port: 8080
+`
+	var result DefaultsStruct
+	err := Unmarshal([]byte(yamlContent), &result)
+	assert.EqualError(t, err, `yamlx: missing required key "name"`)
+}
+
+type MarshalBase struct {
+	ID int `yamlx:"id"`
+}
+
+type MarshalNode struct {
+	MarshalBase `yamlx:",inline"`
+	Name        string            `yamlx:"name,required"`
+	Port        int               `yamlx:"port,default=22,omitempty"`
+	Tags        []string          `yamlx:"tags,flow"`
+	Labels      map[string]string `yamlx:"labels,omitempty"`
+	Parent      *MarshalNode      `yamlx:"parent,omitempty"`
+	Children    []*MarshalNode    `yamlx:"children,omitempty"`
+	Extra       any               `yamlx:"extra,omitempty"`
+	Ignored     string            `yamlx:"-"`
+	Untagged    bool
+	secret      string
+}
+
+func TestMarshal(t *testing.T) {
+	node := MarshalNode{
+		MarshalBase: MarshalBase{ID: 1},
+		Name:        "root",
+		Tags:        []string{"a", "b"},
+		Labels:      map[string]string{"z": "last", "a": "first"},
+		Children: []*MarshalNode{
+			{Name: "child", Port: 80, Extra: map[string]any{"server": MarshalBase{ID: 2}}},
+		},
+		Ignored: "ignored",
+		secret:  "secret",
+	}
+	output, err := Marshal(&node)
+
+	expected := `id: 1
+name: root
+tags: [a, b]
+labels:
+    a: first
+    z: last
+children:
+    - id: 0
+      name: child
+      port: 80
+      tags: []
+      extra:
+        server:
+            id: 2
+      untagged: false
+untagged: false
+`
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(output))
+}
+
+func TestMarshalNonStruct(t *testing.T) {
+	output, err := Marshal(map[string][]*MarshalBase{"servers": {{ID: 1}, nil}})
+	assert.NoError(t, err)
+	assert.Equal(t, "servers:\n    - id: 1\n    - null\n", string(output))
+
+	output, err = Marshal([]int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "- 1\n- 2\n", string(output))
+
+	_, err = Marshal(func() {})
+	assert.Error(t, err)
+}
+
+func TestMarshalYAMLTags(t *testing.T) {
+	type Config struct {
+		Host    string `yaml:"hostname"`
+		Port    int    `yaml:"port,omitempty"`
+		Skipped string `yaml:"-"`
+		Both    string `yaml:"yaml_name" yamlx:"yamlx_name"`
+	}
+	output, err := Marshal(Config{Host: "localhost", Skipped: "x", Both: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hostname: localhost\nyamlx_name: b\n", string(output))
+
+	output, err = Marshal(map[int]string{10: "ten", 2: "two", -1: "minus one"})
+	assert.NoError(t, err)
+	assert.Equal(t, "-1: minus one\n2: two\n10: ten\n", string(output))
+}
+
+func TestEvalWithVars(t *testing.T) {
+	yamlContent := `
+env: &env dev
+replicas: &replicas 1
+`
+	result, err := Eval([]byte(yamlContent), `env + "-" + region`, Options{Vars: map[string]string{"env": "prod", "region": "eu"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "prod-eu", result)
+
+	result, err = Eval([]byte(yamlContent), "replicas * 2", Options{Vars: map[string]string{"replicas": "3"}})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(6), result)
+}
+
+func TestAnchors(t *testing.T) {
+	yamlContent := `
+env: &env dev
+db: &db
+  host: localhost
+  port: 5432
+`
+	anchors, err := Anchors([]byte(yamlContent), Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"env":     "dev",
+		"db":      map[string]any{"host": "localhost", "port": int64(5432)},
+		"db.host": "localhost",
+		"db.port": int64(5432),
+	}, anchors)
+}
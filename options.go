@@ -0,0 +1,35 @@
+package yamlx
+
+// Options configures how yamlx data is decoded.
+type Options struct {
+	// Strict makes decoding fail on keys that don't map to a field of the
+	// destination struct and on keys repeated at the same level of the
+	// document, like yaml.v3's KnownFields.
+	Strict bool
+
+	// Indent is the number of spaces nested blocks are indented by. Render
+	// defaults to 2 when it is 0, while RenderJSON writes compact JSON.
+	Indent int
+
+	// Vars are variables available to aliases and expressions, as if they
+	// were anchors. Values are read like yamlx scalars, so "22" is a number.
+	// A variable overrides an anchor of the same name, so templates can give
+	// defaults that callers replace.
+	Vars map[string]string
+
+	// Trace, if set, has the steps of evaluation added to it: anchors
+	// defined, aliases resolved, expressions evaluated, loop iterations and
+	// merges. It explains how each value came out the way it did.
+	Trace *Trace
+
+	// Filename names the file being evaluated in source maps and errors.
+	Filename string
+
+	// Limits bounds the work evaluation may do, for untrusted templates.
+	Limits Limits
+
+	// Seed seeds the source rand() and the other random functions draw
+	// from. When it is 0 the seed is derived from the document, so the same
+	// document always renders the same way.
+	Seed int64
+}
@@ -0,0 +1,139 @@
+package yamlx
+
+import (
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"sort"
+)
+
+// OrderedMap is a map that remembers the order its keys were first set in.
+// ParseOrdered returns mappings as OrderedMaps so the document's key order
+// survives evaluation.
+type OrderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewOrderedMap returns an empty OrderedMap.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]any)}
+}
+
+// Set sets the value of a key. A key that is already present keeps its
+// position.
+func (m *OrderedMap) Set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of a key and whether it is present.
+func (m *OrderedMap) Get(key string) (any, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Delete removes a key.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order.
+func (m *OrderedMap) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Len returns the number of keys.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// ToMap converts the map, and any OrderedMaps nested in it, to plain maps.
+func (m *OrderedMap) ToMap() map[string]any {
+	result := make(map[string]any, len(m.keys))
+	for _, k := range m.keys {
+		result[k] = plainValue(m.values[k])
+	}
+	return result
+}
+
+// MarshalYAML encodes the map with its keys in order.
+func (m *OrderedMap) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, k := range m.keys {
+		valueNode, err := marshalNode(reflect.ValueOf(m.values[k]))
+		if err != nil {
+			return nil, err
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node, nil
+}
+
+// plainValue converts any OrderedMaps in a value to plain maps.
+func plainValue(value any) any {
+	switch v := value.(type) {
+	case *OrderedMap:
+		return v.ToMap()
+	case []any:
+		l := make([]any, len(v))
+		for i, elem := range v {
+			l[i] = plainValue(elem)
+		}
+		return l
+	}
+	return value
+}
+
+// orderedValue converts any plain maps in a value to OrderedMaps, with their
+// keys sorted.
+func orderedValue(value any) any {
+	switch v := value.(type) {
+	case *OrderedMap:
+		for _, k := range v.keys {
+			v.values[k] = orderedValue(v.values[k])
+		}
+		return v
+	case map[string]any:
+		return toOrderedMap(v)
+	case []any:
+		l := make([]any, len(v))
+		for i, elem := range v {
+			l[i] = orderedValue(elem)
+		}
+		return l
+	}
+	return value
+}
+
+// toOrderedMap returns a mapping value as an OrderedMap, or nil if the value
+// isn't a mapping.
+func toOrderedMap(value any) *OrderedMap {
+	switch v := value.(type) {
+	case *OrderedMap:
+		return v
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		m := NewOrderedMap()
+		for _, k := range keys {
+			m.Set(k, orderedValue(v[k]))
+		}
+		return m
+	}
+	return nil
+}
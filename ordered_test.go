@@ -0,0 +1,75 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"testing"
+)
+
+func TestParseOrdered(t *testing.T) {
+	yamlContent := `
+zone: b
+defaults: &defaults
+  timeout: 30
+  retries: 3
+servers:
+  !for name in [web, db]:
+    - name: ${name}
+      port: 80
+      host: ${name}.local
+custom:
+  <<: *defaults
+  port: 80
+alpha: *defaults
+`
+	lines := strings.Split(yamlContent, "\n")
+	tokens, _ := Tokenize(lines, 0)
+	result, err := ParseOrdered(tokens)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"zone", "defaults", "servers", "custom", "alpha"}, result.Keys())
+
+	custom, _ := result.Get("custom")
+	assert.Equal(t, []string{"timeout", "retries", "port"}, custom.(*OrderedMap).Keys())
+
+	output, err := yaml.Marshal(result)
+	expected := `zone: b
+defaults:
+    timeout: 30
+    retries: 3
+servers:
+    - name: web
+      port: 80
+      host: web.local
+    - name: db
+      port: 80
+      host: db.local
+custom:
+    timeout: 30
+    retries: 3
+    port: 80
+alpha:
+    timeout: 30
+    retries: 3
+`
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(output))
+}
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("b", 1)
+	m.Set("a", NewOrderedMap())
+	m.Set("b", 2)
+	m.Set("c", []any{map[string]any{"y": 1, "x": 2}})
+	m.Delete("a")
+
+	assert.Equal(t, []string{"b", "c"}, m.Keys())
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, map[string]any{"b": 2, "c": []any{map[string]any{"y": 1, "x": 2}}}, m.ToMap())
+
+	ordered := orderedValue(m).(*OrderedMap)
+	c, _ := ordered.Get("c")
+	assert.Equal(t, []string{"x", "y"}, c.([]any)[0].(*OrderedMap).Keys())
+}
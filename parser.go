@@ -1,28 +1,135 @@
 package yamlx
 
 import (
+	"context"
 	"fmt"
 	"github.com/Knetic/govaluate"
+	"math/rand"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
+// parser holds the state of a single parse. Mappings are built as
+// OrderedMaps; anchors holds plain copies of every value expressions can
+// refer to, while values keeps the ordered originals for aliases.
+type parser struct {
+	anchors  map[string]any
+	values   map[string]any
+	vars     map[string]any // Variables set by the caller, which override anchors of the same name
+	sources  SourceMap      // Where each output path came from, e.g. servers[0].host
+	filename string         // File name recorded in sources
+	bindings map[string]any // Loop variables of the loops being expanded
+	trace    *Trace         // Where evaluation steps are recorded, if set
+	steps    *[]*TraceStep  // The list new trace steps are added to
+	limits   *limiter
+	ctx      context.Context // Checked during loops and function calls, if set
+	random   *rand.Rand      // Source of rand() and the other random functions
+	line     int             // Source line of the value being evaluated
+
+	functions map[string]govaluate.ExpressionFunction // Built on first use by expressionFunctions
+}
+
+func newParser(anchors map[string]any) *parser {
+	return &parser{anchors: anchors, values: make(map[string]any), sources: make(SourceMap), limits: newLimiter(Limits{})}
+}
+
+// record notes that the value at path came from a source line, and from an
+// expression if literal contains one.
+func (p *parser) record(path string, line int, literal string) {
+	source := Source{File: p.filename, Line: line}
+	if strings.Contains(literal, "${") {
+		source.Expression = literal
+	}
+	if len(p.bindings) > 0 {
+		source.Bindings = copyBindings(p.bindings)
+	}
+	p.sources[path] = source
+}
+
+func copyBindings(bindings map[string]any) map[string]any {
+	result := make(map[string]any, len(bindings))
+	for name, value := range bindings {
+		result[name] = value
+	}
+	return result
+}
+
 func (t Token) Parse(anchors map[string]any) (any, error) {
+	p := newParser(anchors)
+	p.random = newRandom(0, []*Token{&t})
+	value, err := p.parseToken(&t, "")
+	return plainValue(value), err
+}
+
+// define makes a value available to aliases and expressions under name.
+func (p *parser) define(name string, value any) {
+	p.values[name] = value
+	plain := plainValue(value)
+	p.anchors[name] = plain
+	if m, ok := plain.(map[string]any); ok {
+		for k, v := range createAnchorMap(m, name) {
+			p.anchors[k] = v
+		}
+	}
+}
+
+// alias resolves an alias used at path. The values it copies into the
+// output count towards the node limit.
+func (p *parser) alias(name string, line int, path string) (any, error) {
+	value := p.lookup(name)
+	if p.trace != nil {
+		p.traceStep(&TraceStep{Kind: TraceAlias, Line: line, Path: path, Name: name, Result: plainValue(value)})
+	}
+	if p.limits.MaxNodes > 0 {
+		if err := p.limits.addNodes(countNodes(value, p.limits.MaxNodes)-1, line); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// lookup returns the value of an anchor or loop variable.
+func (p *parser) lookup(name string) any {
+	if value, ok := p.values[name]; ok {
+		return value
+	}
+	return p.anchors[name]
+}
+
+// parseToken parses a token whose value ends up at path in the output.
+func (p *parser) parseToken(t *Token, path string) (any, error) {
+	if t.Type != MERGE_KEY && t.Type != LOOP {
+		if err := p.limits.addNodes(1, t.Line); err != nil {
+			return nil, err
+		}
+	}
+	if path != "" && t.Type != MERGE_KEY {
+		literal := ""
+		if value := t.Attachments.Find(VALUE); value != nil && t.Type == KEY {
+			literal = value.Literal
+		} else if t.Type == VALUE || (t.Type == LIST_ITEM && len(t.Attachments) == 0 && len(t.Children) == 0) {
+			literal = t.Literal
+		}
+		p.record(path, t.Line, literal)
+	}
 	switch t.Type {
 	case KEY:
 		anchor := t.Attachments.Find(ANCHOR)
 		var returnValue any
 		var err error
 		if len(t.Children) > 0 {
-			returnValue, err = parseChildren(t.Children, anchors)
+			returnValue, err = p.parseChildren(t.Children, path)
 		} else if len(t.Attachments) > 0 {
 			value := t.Attachments.Find(VALUE)
 			alias := t.Attachments.Find(ALIAS)
+			valueRange := t.Attachments.Find(LOOP_RANGE)
 			if value != nil {
-				returnValue, err = parseValue(value.Literal, anchors)
+				returnValue, err = p.parseValue(value.Literal, t.Line, path)
 			} else if alias != nil {
-				returnValue = anchors[alias.Literal]
+				returnValue, err = p.alias(alias.Literal, t.Line, path)
+			} else if valueRange != nil {
+				returnValue, err = p.expandRange(valueRange.Literal, t.Line)
 			} else {
 				return nil, fmt.Errorf("key has no value: %s", t)
 			}
@@ -30,59 +137,85 @@ func (t Token) Parse(anchors map[string]any) (any, error) {
 			return nil, fmt.Errorf("key has no value: %s", t)
 		}
 		if anchor != nil {
-			anchors[anchor.Literal] = returnValue
-			if m, ok := returnValue.(map[string]any); ok {
-				for k, v := range createAnchorMap(m, anchor.Literal) {
-					anchors[k] = v
-				}
+			if value, ok := p.vars[anchor.Literal]; ok {
+				returnValue = value
+			}
+			p.define(anchor.Literal, returnValue)
+			if p.trace != nil {
+				p.traceStep(&TraceStep{Kind: TraceAnchor, Line: t.Line, Path: path, Name: anchor.Literal, Result: plainValue(returnValue)})
 			}
 		}
 		return returnValue, err
 	case VALUE:
-		return parseValue(t.Literal, anchors)
+		return p.parseValue(t.Literal, t.Line, path)
 	case LIST_ITEM:
 		value := t.Attachments.Find(VALUE)
 		alias := t.Attachments.Find(ALIAS)
-		if value != nil || alias != nil {
+		valueRange := t.Attachments.Find(LOOP_RANGE)
+		if value != nil || alias != nil || valueRange != nil {
 			var returnValue any
 			var err error
 			if value != nil {
-				returnValue, err = parseValue(value.Literal, anchors)
-				if err != nil {
-					return nil, err
-				}
+				returnValue, err = p.parseValue(value.Literal, t.Line, joinPath(path, t.Literal))
+			} else if alias != nil {
+				returnValue, err = p.alias(alias.Literal, t.Line, joinPath(path, t.Literal))
 			} else {
-				returnValue = anchors[alias.Literal]
+				returnValue, err = p.expandRange(valueRange.Literal, t.Line)
 			}
-			newMap := map[string]any{t.Literal: returnValue}
+			if err != nil {
+				return nil, err
+			}
+			literal := ""
+			if value != nil {
+				literal = value.Literal
+			}
+			p.record(joinPath(path, t.Literal), t.Line, literal)
+			newMap := NewOrderedMap()
+			newMap.Set(t.Literal, returnValue)
 			for _, child := range t.Children {
 				if child.Type == KEY {
-					childValue, err := child.Parse(anchors)
+					childValue, err := p.parseToken(child, joinPath(path, child.Literal))
 					if err != nil {
 						return newMap, err
 					}
-					newMap[child.Literal] = childValue
+					newMap.Set(child.Literal, childValue)
 				} else {
 					return newMap, fmt.Errorf("invalid child type: %s", child)
 				}
 			}
 			return newMap, nil
 		} else if len(t.Children) > 0 {
-			returnValue, err := parseChildren(t.Children, anchors)
-			return map[string]any{t.Literal: returnValue}, err
+			itemPath := joinPath(path, t.Literal)
+			p.record(itemPath, t.Line, "")
+			returnValue, err := p.parseChildren(t.Children, itemPath)
+			newMap := NewOrderedMap()
+			newMap.Set(t.Literal, returnValue)
+			return newMap, err
 		} else {
-			return parseValue(t.Literal, anchors)
+			return p.parseValue(t.Literal, t.Line, path)
 		}
 	case MERGE_KEY:
-		anchorValue := anchors[t.Literal]
+		anchorValue := p.lookup(t.Literal)
 		if anchorValue == nil {
 			return nil, fmt.Errorf("anchor not found: %s", t.Literal)
 		}
+		if p.trace != nil {
+			p.traceStep(&TraceStep{Kind: TraceMerge, Line: t.Line, Path: path, Name: t.Literal, Result: plainValue(anchorValue)})
+		}
+		if p.limits.MaxNodes > 0 {
+			if err := p.limits.addNodes(countNodes(anchorValue, p.limits.MaxNodes)-1, t.Line); err != nil {
+				return nil, err
+			}
+		}
+		if m := toOrderedMap(anchorValue); m != nil {
+			for _, k := range m.keys {
+				p.record(joinPath(path, k), t.Line, "")
+			}
+		}
 		return anchorValue, nil
 	default:
 		return nil, fmt.Errorf("unknown token type: %s", t)
 	}
-	return nil, nil
 }
 
 func createAnchorMap(value map[string]any, prefix string) map[string]any {
@@ -99,9 +232,14 @@ func createAnchorMap(value map[string]any, prefix string) map[string]any {
 	return returnMap
 }
 
-func parseChildren(tokens []*Token, anchors map[string]any) (any, error) {
+// parseChildren parses the children of a token whose value ends up at path.
+func (p *parser) parseChildren(tokens []*Token, path string) (any, error) {
+	if err := p.limits.enter(tokens[0].Line); err != nil {
+		return nil, err
+	}
+	defer p.limits.leave()
+
 	var returnValue any
-	var err error
 	isList := tokens[0].Type == LIST_ITEM
 	if tokens[0].Type == LOOP {
 		isList = tokens[0].Children[0].Type == LIST_ITEM
@@ -110,25 +248,10 @@ func parseChildren(tokens []*Token, anchors map[string]any) (any, error) {
 		l := make([]any, 0)
 		for _, child := range tokens {
 			if child.Type == LOOP {
-				r := child.Attachments.Find(LOOP_RANGE)
-				rangeString := r.Literal
-				arr := make([]any, 0)
-				if strings.HasPrefix(rangeString, "*") {
-					parts := strings.SplitN(rangeString, "*", 2)
-					anchorKey := strings.TrimSpace(parts[1])
-					arr = anchors[anchorKey].([]any)
-				} else if strings.Contains(rangeString, "..") {
-					parts := strings.SplitN(rangeString, "..", 2)
-					start, _ := strconv.ParseInt(parts[0], 10, 64)
-					end, _ := strconv.ParseInt(parts[1], 10, 64)
-					for i := start; i <= end; i++ {
-						arr = append(arr, i)
-					}
-				} else {
-					parts := strings.Split(rangeString, ",")
-					for _, part := range parts {
-						arr = append(arr, part)
-					}
+				rangeString := child.Attachments.Find(LOOP_RANGE).Literal
+				values, err := p.parseRange(rangeString)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", child.Line, err)
 				}
 				keys := strings.Split(child.Literal, ",")
 				variableKey := keys[0]
@@ -137,111 +260,302 @@ func parseChildren(tokens []*Token, anchors map[string]any) (any, error) {
 					variableKey = strings.TrimSpace(keys[1])
 					indexKey = strings.TrimSpace(keys[0])
 				}
-				for _, nestedChild := range child.Children {
-					for i, v := range arr {
-						anchors[variableKey] = v
+				outer := p.bindings
+				p.bindings = make(map[string]any, len(outer)+2)
+				for name, value := range outer {
+					p.bindings[name] = value
+				}
+				var loopStep *TraceStep
+				outerSteps := p.steps
+				if p.trace != nil {
+					loopStep = &TraceStep{Kind: TraceLoop, Line: child.Line, Path: path, Name: child.Literal, Expression: rangeString, Result: []any{}}
+					p.traceStep(loopStep)
+				}
+				for j, nestedChild := range child.Children {
+					for i := 0; i < values.len; i++ {
+						if err := p.limits.iterate(1, nestedChild.Line); err != nil {
+							return nil, err
+						}
+						if err := p.checkContext(nestedChild.Line); err != nil {
+							return nil, err
+						}
+						v := values.at(i)
+						if loopStep != nil && j == 0 {
+							// The range is recorded as it's expanded, so it stays within the limits
+							loopStep.Result = append(loopStep.Result.([]any), plainValue(v))
+						}
+						p.define(variableKey, v)
+						p.bindings[variableKey] = plainValue(v)
 						if indexKey != "" {
-							anchors[indexKey] = i
+							p.define(indexKey, i)
+							p.bindings[indexKey] = i
+						}
+						if loopStep != nil {
+							iteration := &TraceStep{Kind: TraceIteration, Line: nestedChild.Line, Path: fmt.Sprintf("%s[%d]", path, len(l)), Inputs: copyBindings(p.bindings)}
+							loopStep.Steps = append(loopStep.Steps, iteration)
+							p.steps = &iteration.Steps
+						}
+						elem, err := p.parseToken(nestedChild, fmt.Sprintf("%s[%d]", path, len(l)))
+						if err != nil {
+							return nil, err
 						}
-						elem, _ := nestedChild.Parse(anchors)
 						l = append(l, elem)
 					}
 				}
+				p.steps = outerSteps
+				p.bindings = outer
 			} else {
-				v, _ := child.Parse(anchors)
+				v, err := p.parseToken(child, fmt.Sprintf("%s[%d]", path, len(l)))
+				if err != nil {
+					return nil, err
+				}
 				l = append(l, v)
 			}
 		}
 		returnValue = l
 	} else {
-		m := make(map[string]any)
-		var value any
+		m := NewOrderedMap()
 		for _, child := range tokens {
-			value, err = child.Parse(anchors)
+			childPath := joinPath(path, child.Literal)
+			if child.Type == MERGE_KEY {
+				childPath = path
+			}
+			value, err := p.parseToken(child, childPath)
+			if err != nil {
+				return nil, err
+			}
 			if value != nil {
-				if valueMap, ok := value.(map[string]any); ok && child.Type == MERGE_KEY {
-					for k, v := range valueMap {
-						m[k] = v
+				if valueMap := toOrderedMap(value); valueMap != nil && child.Type == MERGE_KEY {
+					for _, k := range valueMap.keys {
+						m.Set(k, valueMap.values[k])
 					}
 				} else {
-					m[child.Literal] = value
+					m.Set(child.Literal, value)
 				}
 			}
 		}
 		returnValue = m
 	}
-	return returnValue, err
+	return returnValue, nil
+}
+
+func parseValue(literal string, anchors map[string]any, functions map[string]govaluate.ExpressionFunction) (any, error) {
+	literal, err := replaceWithMap(literal, func(expression string) (any, error) {
+		return evaluateWithFunctions(expression, anchors, functions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseScalar(literal), nil
+}
+
+// expressionFunctions returns the functions expressions can call, with the
+// random functions drawing from the parser's source and every function
+// checking the parser's context before each call.
+func (p *parser) expressionFunctions() map[string]govaluate.ExpressionFunction {
+	if p.ctx == nil && p.random == nil {
+		return functions
+	}
+	if p.functions == nil {
+		p.functions = make(map[string]govaluate.ExpressionFunction, len(functions))
+		for name, function := range functions {
+			function := function
+			if random, ok := randomFunctions[name]; ok && p.random != nil {
+				function = func(args ...any) (any, error) { return random(p.random, args...) }
+			}
+			if scoped, ok := scopedFunctions[name]; ok {
+				function = func(args ...any) (any, error) {
+					return scoped(scope{p.anchors, p.expressionFunctions(), func(n int) error {
+						return p.limits.iterate(n, p.line)
+					}, func(n int) error {
+						return p.limits.checkSize(n, p.line)
+					}}, args...)
+				}
+			}
+			if p.ctx != nil {
+				next := function
+				function = func(args ...any) (any, error) {
+					if err := p.checkContext(p.line); err != nil {
+						return nil, err
+					}
+					return next(args...)
+				}
+			}
+			p.functions[name] = function
+		}
+	}
+	return p.functions
 }
 
-func parseValue(literal string, anchors map[string]any) (any, error) {
-	literal, err := replaceWithMap(literal, anchors)
+// parseValue parses the value written on a source line for path, evaluating
+// its expressions against the anchors defined so far.
+func (p *parser) parseValue(literal string, line int, path string) (any, error) {
+	whole := wholeExpressionRegex.MatchString(literal)
+	var result any
+	var text bool
+	literal, err := replaceWithMap(literal, func(expression string) (any, error) {
+		text = textCall(expression)
+		if err := p.limits.checkExpression(expression, line); err != nil {
+			return nil, err
+		}
+		p.line = line
+		var err error
+		result, err = evaluateWithFunctions(expression, p.anchors, p.expressionFunctions())
+		if p.trace != nil {
+			p.traceExpression(expression, line, path, result, err)
+		}
+		if err == nil {
+			err = p.limits.checkString(result, line)
+		}
+		return result, err
+	})
 	if err != nil {
 		return nil, err
 	}
+	switch result.(type) {
+	case []any, map[string]any:
+		// A value that is a single expression keeps a list or map whole
+		if whole {
+			if p.limits.MaxNodes > 0 {
+				if err := p.limits.addNodes(countNodes(result, p.limits.MaxNodes)-1, line); err != nil {
+					return nil, err
+				}
+			}
+			return orderedValue(result), nil
+		}
+	case string:
+		// and a function returning text keeps it a string, e.g. "00042"
+		if whole && text {
+			return result, nil
+		}
+	}
+	if err := p.limits.checkString(literal, line); err != nil {
+		return nil, err
+	}
+	return parseScalar(literal), nil
+}
 
+// parseScalar converts a literal to a number, bool or string.
+func parseScalar(literal string) any {
 	if i, err := strconv.ParseInt(literal, 10, 64); err == nil {
-		return i, nil
+		return i
 	}
 	if f, err := strconv.ParseFloat(literal, 64); err == nil {
-		return f, nil
+		return f
 	}
 	if b, err := strconv.ParseBool(literal); err == nil {
-		return b, nil
+		return b
 	}
-	if strings.HasPrefix(literal, "\"") && strings.HasSuffix(literal, "\"") {
-		return literal[1 : len(literal)-1], nil
+	if len(literal) >= 2 && strings.HasPrefix(literal, "\"") && strings.HasSuffix(literal, "\"") {
+		return literal[1 : len(literal)-1]
 	}
-	return literal, nil
+	return literal
 }
 
 var functions = map[string]govaluate.ExpressionFunction{
-	"len":        length,
-	"contains":   contains,
-	"rand":       calcRand,
-	"max":        calcMax,
-	"min":        calcMin,
-	"upper":      upper,
-	"lower":      lower,
-	"title":      title,
-	"trim":       trim,
-	"join":       join,
-	"replace":    replace,
-	"substr":     substr,
-	"strrev":     strrev,
-	"startswith": startswith,
-	"endswith":   endswith,
-	"alltrue":    alltrue,
-	"anytrue":    anytrue,
+	"len":            length,
+	"contains":       contains,
+	"rand":           withGlobalRandom(calcRand),
+	"randint":        withGlobalRandom(randint),
+	"randf":          withGlobalRandom(randf),
+	"choice":         withGlobalRandom(choice),
+	"shuffle":        withGlobalRandom(shuffle),
+	"sample":         withGlobalRandom(sample),
+	"randstr":        withGlobalRandom(randstr),
+	"max":            calcMax,
+	"min":            calcMin,
+	"upper":          upper,
+	"lower":          lower,
+	"title":          title,
+	"trim":           trim,
+	"join":           join,
+	"replace":        replace,
+	"substr":         substr,
+	"strrev":         strrev,
+	"startswith":     startswith,
+	"endswith":       endswith,
+	"alltrue":        alltrue,
+	"anytrue":        anytrue,
+	"keys":           keys,
+	"values":         values,
+	"sort":           sortList,
+	"sortby":         sortby,
+	"uniq":           uniq,
+	"reverse":        reverse,
+	"flatten":        flatten,
+	"concat":         concat,
+	"slice":          slice,
+	"first":          first,
+	"last":           last,
+	"zip":            zip,
+	"get":            get,
+	"has":            has,
+	"merge":          merge,
+	"pick":           pick,
+	"omit":           omit,
+	"dict":           dict,
+	"items":          items,
+	"format":         format,
+	"printf":         format,
+	"regex_match":    regexMatch,
+	"regex_replace":  regexReplace,
+	"regex_find_all": regexFindAll,
+	"split":          split,
+	"quote":          quote,
+	"truncate":       truncate,
+	"slugify":        slugify,
+	"camel":          camel,
+	"snake":          snake,
+	"kebab":          kebab,
+	"b64enc":         b64enc,
+	"b64dec":         b64dec,
+	"hex":            hexEncode,
+	"urlencode":      urlencode,
+	"sha256":         sha256sum,
+	"sha1":           sha1sum,
+	"md5":            md5sum,
+	"crc32":          crc32sum,
+	"tojson":         tojson,
+	"fromjson":       fromjson,
+	"toyaml":         toyaml,
+	"fromyaml":       fromyaml,
+	"uuid5":          uuid5,
 }
 
-func replaceWithMap(input string, anchors map[string]any) (string, error) {
+// textFunctions return text on purpose, like a hash or a zero-padded number,
+// so a value that is a single call to one stays a string instead of being
+// read as a number or bool.
+var textFunctions = map[string]bool{
+	"format": true, "printf": true, "pad": true, "indent": true, "quote": true,
+	"truncate": true, "regex_replace": true, "slugify": true, "camel": true,
+	"snake": true, "kebab": true, "randstr": true, "b64enc": true,
+	"b64dec": true, "hex": true, "urlencode": true, "sha256": true,
+	"sha1": true, "md5": true, "crc32": true, "tojson": true, "toyaml": true,
+	"uuid5": true,
+}
+
+// textCall reports whether an expression is a single call to one of the
+// textFunctions.
+func textCall(expression string) bool {
+	expression = strings.TrimSpace(expression)
+	open := strings.IndexByte(expression, '(')
+	return open > 0 && textFunctions[strings.TrimSpace(expression[:open])] &&
+		closingBracket(expression, open) == len(expression)-1
+}
+
+// wholeExpressionRegex matches a value that is a single ${} expression.
+var wholeExpressionRegex = regexp.MustCompile(`^\s*\$\{[^\}]+\}\s*$`)
+
+// replaceWithMap replaces each ${} expression in input with its result,
+// using evaluate to evaluate the expressions.
+func replaceWithMap(input string, evaluate func(expression string) (any, error)) (string, error) {
 	// Regular expression to find ${} patterns
 	re := regexp.MustCompile(`\$\{([^\}]+)\}`)
 	matches := re.FindAllStringSubmatch(input, -1)
 
 	outputString := input
 	for _, m := range matches {
-		expressionString := m[1]
-
-		// Wrap any anchors in square brackets
-		for k, _ := range anchors {
-			if expressionString == k {
-				expressionString = fmt.Sprintf("[%v]", k)
-			} else {
-				index := strings.Index(expressionString, k)
-				if index >= 0 && (index+len(k) < len(expressionString) && expressionString[index+len(k)] == ' ' || index+len(k) == len(expressionString)) {
-					expressionString = strings.Replace(expressionString, k, fmt.Sprintf("[%v]", k), -1)
-				}
-			}
-		}
-
-		// Evaluate the expression
-		expression, err := govaluate.NewEvaluableExpressionWithFunctions(expressionString, functions)
-		if err != nil {
-			return "", err
-		}
-
-		result, err := expression.Evaluate(anchors)
+		result, err := evaluate(m[1])
 		if err != nil {
 			return "", err
 		}
@@ -253,16 +567,149 @@ func replaceWithMap(input string, anchors map[string]any) (string, error) {
 	return outputString, nil
 }
 
+// evaluateExpression evaluates the contents of a ${} expression.
+func evaluateExpression(expressionString string, anchors map[string]any) (any, error) {
+	return evaluateWithFunctions(expressionString, anchors, functions)
+}
+
+// evaluateWithFunctions is like evaluateExpression but with the functions
+// expressions can call.
+func evaluateWithFunctions(expressionString string, anchors map[string]any, functions map[string]govaluate.ExpressionFunction) (any, error) {
+	expressionString, literals := listLiterals(expressionString)
+	lists := make(map[string]any, len(literals))
+	for name, elements := range literals {
+		list := make([]any, len(elements))
+		for i, element := range elements {
+			value, err := evaluateWithFunctions(element, anchors, functions)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		lists[name] = list
+	}
+	expressionString = wrapAnchors(expressionString, func(name string) bool {
+		_, ok := anchors[name]
+		return ok
+	})
+
+	// Evaluate the expression
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(expressionString, functions)
+	if err != nil {
+		return nil, err
+	}
+	result, err := expression.Eval(expressionParameters{anchors, lists})
+	return plainList(result), err
+}
+
+// wrapAnchors wraps the anchor names in an expression in square brackets, so
+// govaluate reads names like db.port or my-anchor as a single variable.
+func wrapAnchors(expression string, isAnchor func(string) bool) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range anchorSpans(expression, isAnchor) {
+		b.WriteString(expression[last:span[0]])
+		b.WriteString("[" + expression[span[0]:span[1]] + "]")
+		last = span[1]
+	}
+	b.WriteString(expression[last:])
+	return b.String()
+}
+
+// anchorSpans returns the start and end offsets of the anchor names in an
+// expression. Where names overlap the longest one wins, and text inside
+// string literals and function names are skipped.
+func anchorSpans(expression string, isAnchor func(string) bool) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(expression); {
+		ch := expression[i]
+		if ch == '"' || ch == '\'' {
+			end := strings.IndexByte(expression[i+1:], ch)
+			if end < 0 {
+				break
+			}
+			i += end + 2
+			continue
+		}
+		if !isIdentifierStart(ch) || (i > 0 && isNameChar(expression[i-1])) {
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(expression) && (isNameChar(expression[end]) || expression[end] == '.' || expression[end] == '-') {
+			end++
+		}
+		name := ""
+		for j := end; j > i; j-- {
+			// Try the longest name that ends on a word boundary
+			if (j == end || !isNameChar(expression[j])) && isNameChar(expression[j-1]) && isAnchor(expression[i:j]) {
+				name = expression[i:j]
+				break
+			}
+		}
+		rest := strings.TrimLeft(expression[i+len(name):], " ")
+		if name != "" && !strings.HasPrefix(rest, "(") {
+			spans = append(spans, [2]int{i, i + len(name)})
+			i += len(name)
+			continue
+		}
+		// Not an anchor, so skip the identifier, including any dotted parts
+		for i < len(expression) && (isNameChar(expression[i]) || expression[i] == '.') {
+			i++
+		}
+	}
+	return spans
+}
+
+func isIdentifierStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isNameChar(ch byte) bool {
+	return isIdentifierStart(ch) || (ch >= '0' && ch <= '9')
+}
+
 func Parse(tokens []*Token) (map[string]any, error) {
-	result := make(map[string]any)
-	anchors := make(map[string]any)
+	result, err := newParser(make(map[string]any)).parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return result.ToMap(), nil
+}
+
+// ParseWithSourceMap is like Parse, but also returns where each value in the
+// result came from. filename is recorded in the map as the file of every
+// value, and may be empty.
+func ParseWithSourceMap(tokens []*Token, filename string) (map[string]any, SourceMap, error) {
+	p := newParser(make(map[string]any))
+	p.filename = filename
+	result, err := p.parse(tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.ToMap(), p.sources, nil
+}
+
+// ParseOrdered is like Parse, but keeps the order keys appear in the
+// document by returning every mapping as an OrderedMap.
+func ParseOrdered(tokens []*Token) (*OrderedMap, error) {
+	return newParser(make(map[string]any)).parse(tokens)
+}
+
+// parse parses the top level tokens of a document.
+func (p *parser) parse(tokens []*Token) (*OrderedMap, error) {
+	if p.random == nil {
+		p.random = newRandom(0, tokens)
+	}
+	result := NewOrderedMap()
 	for _, token := range tokens {
-		value, err := token.Parse(anchors)
+		value, err := p.parseToken(token, token.Literal)
 		if err != nil {
 			return nil, err
 		}
 		if value != nil {
-			result[token.Literal] = value
+			result.Set(token.Literal, orderedValue(value))
 		}
 	}
 	return result, nil
@@ -346,3 +346,30 @@ servers:
 	assert.NoError(t, err)
 	assert.Equal(t, expected, result)
 }
+
+func TestCommentParsing(t *testing.T) {
+	// A # only starts a comment at the start of a line or after whitespace,
+	// and never inside quotes, expressions or flow lists
+	yamlContent := `
+# a comment
+plain: value # a comment
+attached: b#c
+quoted: "x # y"
+expression: ${"#" + "1"}
+list: [a, "b # c"] # a comment
+`
+	lines := strings.Split(yamlContent, "\n")
+	tokens, _ := Tokenize(lines, 0)
+	result, err := Parse(tokens)
+
+	expected := map[string]any{
+		"plain":      "value",
+		"attached":   "b#c",
+		"quoted":     "x # y",
+		"expression": "#1",
+		"list":       []any{"a", "b # c"},
+	}
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+}
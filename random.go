@@ -0,0 +1,194 @@
+package yamlx
+
+import (
+	"fmt"
+	"github.com/Knetic/govaluate"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// randomFunction is a function that draws from a random source.
+type randomFunction func(r *rand.Rand, args ...any) (any, error)
+
+// randomFunctions are bound to the random source of each evaluation, so
+// the same template and seed always render the same way.
+var randomFunctions = map[string]randomFunction{
+	"rand":    calcRand,
+	"randint": randint,
+	"randf":   randf,
+	"choice":  choice,
+	"shuffle": shuffle,
+	"sample":  sample,
+	"randstr": randstr,
+}
+
+// globalRandom is the source random functions draw from outside of an
+// evaluation, e.g. when evaluateExpression is called directly.
+var globalRandom = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// withGlobalRandom binds a random function to globalRandom.
+func withGlobalRandom(function randomFunction) govaluate.ExpressionFunction {
+	return func(args ...any) (any, error) {
+		globalRandom.Lock()
+		defer globalRandom.Unlock()
+		return function(globalRandom.Rand, args...)
+	}
+}
+
+// newRandom returns a random source for evaluating tokens. A seed of 0 is
+// replaced by a hash of the tokens.
+func newRandom(seed int64, tokens []*Token) *rand.Rand {
+	if seed == 0 {
+		seed = seedTokens(tokens)
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// seedTokens hashes the types and literals of a token tree.
+func seedTokens(tokens []*Token) int64 {
+	h := fnv.New64a()
+	var walk func(tokens []*Token)
+	walk = func(tokens []*Token) {
+		for _, token := range tokens {
+			fmt.Fprintf(h, "%v\x00%s\x00%d\x00", token.Type, token.Literal, token.Line)
+			walk(token.Attachments)
+			h.Write([]byte{1})
+			walk(token.Children)
+			h.Write([]byte{2})
+		}
+	}
+	walk(tokens)
+	return int64(h.Sum64())
+}
+
+// calcRand picks a random element of a list, or a random number between
+// two numbers: an integer within both bounds if they are whole numbers, and
+// otherwise a float like randf.
+func calcRand(r *rand.Rand, args ...any) (any, error) {
+	if len(args) == 2 {
+		if min, ok := args[0].(float64); ok {
+			max, ok := args[1].(float64)
+			if !ok {
+				return nil, fmt.Errorf("rand function requires 2 numeric arguments")
+			}
+			if min == math.Trunc(min) && max == math.Trunc(max) {
+				return randint(r, min, max)
+			}
+			return randf(r, min, max)
+		}
+	}
+	return choice(r, args...)
+}
+
+// randint returns a random integer between min and max, inclusive.
+func randint(r *rand.Rand, args ...any) (any, error) {
+	min, max, err := randomBounds("randint", args)
+	if err != nil {
+		return nil, err
+	}
+	if min != math.Trunc(min) || max != math.Trunc(max) {
+		return nil, fmt.Errorf("randint function requires whole numbers")
+	}
+	// float64(math.MaxInt64) is 2^63, one past the largest int64
+	if min < math.MinInt64 || max >= math.MaxInt64 || max-min >= math.MaxInt64 {
+		return nil, fmt.Errorf("randint function requires bounds less than 2^63 apart")
+	}
+	return r.Int63n(int64(max-min)+1) + int64(min), nil
+}
+
+// randf returns a random float from min up to but not including max, or
+// between 0 and 1 if no bounds are given.
+func randf(r *rand.Rand, args ...any) (any, error) {
+	if len(args) == 0 {
+		return r.Float64(), nil
+	}
+	min, max, err := randomBounds("randf", args)
+	if err != nil {
+		return nil, err
+	}
+	return min + r.Float64()*(max-min), nil
+}
+
+// randomBounds checks the min and max arguments of a random function.
+func randomBounds(name string, args []any) (float64, float64, error) {
+	if len(args) != 2 {
+		return 0, 0, fmt.Errorf("%s function requires 2 arguments", name)
+	}
+	min, ok := args[0].(float64)
+	max, ok2 := args[1].(float64)
+	if !ok || !ok2 {
+		return 0, 0, fmt.Errorf("%s function requires numeric arguments", name)
+	}
+	if max < min {
+		return 0, 0, fmt.Errorf("%s function requires min to be at most max", name)
+	}
+	return min, max, nil
+}
+
+// choice returns a random element of a list, or of its arguments.
+func choice(r *rand.Rand, args ...any) (any, error) {
+	items := spread(args)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("choice function requires a non-empty list")
+	}
+	return items[r.Intn(len(items))], nil
+}
+
+func shuffle(r *rand.Rand, args ...any) (any, error) {
+	result := append(List(nil), spread(args)...)
+	r.Shuffle(len(result), func(i, j int) { result[i], result[j] = result[j], result[i] })
+	return result, nil
+}
+
+func sample(r *rand.Rand, args ...any) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("sample function requires a slice and a count")
+	}
+	count, ok := args[len(args)-1].(float64)
+	items := args[:len(args)-1]
+	if list, isList := args[0].(List); isList && len(args) == 2 {
+		items = list
+	}
+	if !ok || !(count >= 0 && count <= float64(len(items))) {
+		return nil, fmt.Errorf("sample function requires a count between 0 and %d", len(items))
+	}
+	result := make(List, int(count))
+	for i, j := range r.Perm(len(items))[:len(result)] {
+		result[i] = items[j]
+	}
+	return result, nil
+}
+
+const randstrCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randstr(r *rand.Rand, args ...any) (any, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("randstr function requires a length and an optional charset")
+	}
+	length, ok := args[0].(float64)
+	if !ok {
+		return nil, fmt.Errorf("randstr function requires a numeric length")
+	}
+	if err := checkLength("randstr", "length", length); err != nil {
+		return nil, err
+	}
+	charset := []rune(randstrCharset)
+	if len(args) == 2 {
+		s, ok := args[1].(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("randstr function requires a non-empty charset")
+		}
+		charset = []rune(s)
+	}
+	result := make([]rune, int(length))
+	for i := range result {
+		result[i] = charset[r.Intn(len(charset))]
+	}
+	return string(result), nil
+}
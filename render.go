@@ -0,0 +1,171 @@
+package yamlx
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+)
+
+// Render evaluates a yamlx template and returns the result as plain yaml,
+// without going through a Go struct. Keys keep their order from the
+// template, and comments and quoting on lines that aren't inside a loop are
+// carried over to the output.
+func Render(data []byte, opts Options) ([]byte, error) {
+	result, p, err := evaluate(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renderer{sources: p.sources, static: make(map[int]*Node), used: make(map[int]bool)}
+	r.collectStatic(doc.Nodes)
+	root, err := r.node(result, "")
+	if err != nil {
+		return nil, err
+	}
+	document := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	document.FootComment = commentLines(doc.Trailing)
+
+	indent := opts.Indent
+	if indent <= 0 {
+		indent = 2
+	}
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// renderer turns an evaluated document into yaml nodes, decorating them with
+// the comments and quoting of the source lines they came from.
+type renderer struct {
+	sources SourceMap
+	static  map[int]*Node // Source nodes outside of loops, by line
+	used    map[int]bool  // Lines whose comments have been placed
+}
+
+// collectStatic records the source nodes that aren't inside a loop.
+func (r *renderer) collectStatic(nodes []*Node) {
+	for _, node := range nodes {
+		if node.Type == LOOP {
+			continue
+		}
+		r.static[node.Line] = node
+		r.collectStatic(node.Children)
+	}
+}
+
+// source returns the static source node a path came from, if any.
+func (r *renderer) source(path string) *Node {
+	source, ok := r.sources[path]
+	if !ok {
+		return nil
+	}
+	return r.static[source.Line]
+}
+
+// node builds the yaml node for the value at path.
+func (r *renderer) node(value any, path string) (*yaml.Node, error) {
+	switch v := value.(type) {
+	case *OrderedMap:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range v.keys {
+			keyPath := joinPath(path, k)
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}
+			source := r.claim(keyPath)
+			valueNode, err := r.node(v.values[k], keyPath)
+			if err != nil {
+				return nil, err
+			}
+			decorate(keyNode, valueNode, source)
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+		return node, nil
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for i, elem := range v {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			var source *Node
+			if _, isMap := elem.(*OrderedMap); !isMap {
+				// The comments of "- key: value" items go on their first key
+				source = r.claim(elemPath)
+			}
+			elemNode, err := r.node(elem, elemPath)
+			if err != nil {
+				return nil, err
+			}
+			decorate(elemNode, elemNode, source)
+			node.Content = append(node.Content, elemNode)
+		}
+		return node, nil
+	default:
+		node, err := marshalNode(reflect.ValueOf(value))
+		if err != nil {
+			return nil, err
+		}
+		if source := r.source(path); source != nil && source.Alias == "" {
+			if s, ok := value.(string); ok && !strings.Contains(source.Value, "${") {
+				node.Style = quoteStyle(source.Value)
+				node.Tag, node.Value = "!!str", s
+			}
+		}
+		return node, nil
+	}
+}
+
+// claim returns the static source node of path, unless its comments have
+// already been placed on another node.
+func (r *renderer) claim(path string) *Node {
+	source := r.source(path)
+	if source == nil || r.used[source.Line] {
+		return nil
+	}
+	r.used[source.Line] = true
+	return source
+}
+
+// decorate copies the comments of a source line onto a key node, or onto the
+// value itself when it is a scalar.
+func decorate(keyNode *yaml.Node, valueNode *yaml.Node, source *Node) {
+	if source == nil {
+		return
+	}
+	keyNode.HeadComment = commentLines(source.Head)
+	if valueNode.Kind == yaml.ScalarNode {
+		valueNode.LineComment = source.Comment
+	} else {
+		keyNode.LineComment = source.Comment
+	}
+}
+
+// quoteStyle returns the yaml style matching how a source value was quoted.
+// Only double quotes are carried over, as yamlx keeps single quotes as part
+// of the value.
+func quoteStyle(value string) yaml.Style {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return yaml.DoubleQuotedStyle
+	}
+	return 0
+}
+
+// commentLines joins the comment lines among head lines, dropping blank ones.
+func commentLines(lines []string) string {
+	var comments []string
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			comments = append(comments, trimmed)
+		}
+	}
+	return strings.Join(comments, "\n")
+}
@@ -0,0 +1,51 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	yamlContent := `# Deployment settings
+version: "42"
+environments: &environments [dev, prod] # all of them
+
+servers:
+  # one per environment
+  !for idx, name in *environments:
+    - name: ${name} # repeated
+      port: ${8000 + idx}
+zone: eu-west-1 # primary
+# end of file
+`
+	output, err := Render([]byte(yamlContent), Options{})
+
+	expected := `# Deployment settings
+version: "42"
+environments: # all of them
+  - dev
+  - prod
+servers:
+  - name: dev
+    port: 8000
+  - name: prod
+    port: 8001
+zone: eu-west-1 # primary
+
+# end of file
+`
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(output))
+}
+
+func TestRenderIndent(t *testing.T) {
+	output, err := Render([]byte("a:\n  b: 1\n"), Options{Indent: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, "a:\n    b: 1\n", string(output))
+}
+
+func TestRenderAcceptsWhatParseDoes(t *testing.T) {
+	output, err := Render([]byte("a:1\nb: 2 # two\n"), Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1\nb: 2 # two\n", string(output))
+}
@@ -0,0 +1,14 @@
+package yamlx
+
+// Source is where a value in evaluated output came from.
+type Source struct {
+	File       string         `json:"file,omitempty"`
+	Line       int            `json:"line"`                 // 1-based
+	Expression string         `json:"expression,omitempty"` // The value as written, if it has an expression, e.g. "${name}.example.com"
+	Bindings   map[string]any `json:"bindings,omitempty"`   // The loop variables at the time, e.g. {"idx": 1, "name": "dev"}
+}
+
+// SourceMap maps paths in evaluated output, like "servers[3].host", to where
+// their values came from. Mappings and list items have entries of their own,
+// and keys merged in with << map to the line of the merge key.
+type SourceMap map[string]Source
@@ -0,0 +1,27 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseWithSourceMap(t *testing.T) {
+	yamlContent := `environments: &environments [dev, prod]
+servers:
+  !for idx, name in *environments:
+    - host: ${name}.example.com
+      port: 22
+`
+	tokens, err := Tokenize(strings.Split(yamlContent, "\n"), 0)
+	assert.Nil(t, err)
+	result, sources, err := ParseWithSourceMap(tokens, "config.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "prod.example.com", result["servers"].([]any)[1].(map[string]any)["host"])
+
+	bindings := map[string]any{"idx": 1, "name": "prod"}
+	assert.Equal(t, Source{"config.yaml", 4, "${name}.example.com", bindings}, sources["servers[1].host"])
+	assert.Equal(t, Source{"config.yaml", 5, "", bindings}, sources["servers[1].port"])
+	assert.Equal(t, Source{"config.yaml", 4, "", bindings}, sources["servers[1]"])
+	assert.Equal(t, Source{"config.yaml", 1, "", nil}, sources["environments"])
+}
@@ -0,0 +1,116 @@
+package yamlx
+
+import (
+	"fmt"
+	"sort"
+)
+
+// checkDuplicateKeys reports the first key that appears more than once at
+// the same level of the token tree.
+func checkDuplicateKeys(tokens Tokens, path string) error {
+	seen := make(map[string]*Token)
+	index := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case KEY:
+			keyPath := joinPath(path, token.Literal)
+			if first, ok := seen[token.Literal]; ok {
+				return fmt.Errorf("yamlx: duplicate key %q on line %d (first defined on line %d)", keyPath, token.Line, first.Line)
+			}
+			seen[token.Literal] = token
+			if err := checkDuplicateKeys(token.Children, keyPath); err != nil {
+				return err
+			}
+		case LIST_ITEM:
+			if err := checkListItem(token, fmt.Sprintf("%s[%d]", path, index)); err != nil {
+				return err
+			}
+			index++
+		case LOOP:
+			// Every iteration produces the same keys, so the loop body is
+			// checked once under a wildcard index.
+			for _, child := range token.Children {
+				if err := checkListItem(child, path+"[*]"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkListItem checks the keys of a single list item.
+func checkListItem(token *Token, path string) error {
+	if token.Type != LIST_ITEM {
+		return checkDuplicateKeys(Tokens{token}, path)
+	}
+	if len(token.Attachments) > 0 {
+		// "- key: value" starts a mapping made of the item and its children
+		first := &Token{Type: KEY, Literal: token.Literal, Line: token.Line}
+		return checkDuplicateKeys(append(Tokens{first}, token.Children...), path)
+	}
+	return checkDuplicateKeys(token.Children, joinPath(path, token.Literal))
+}
+
+// unknownKeyError describes a key that has no matching struct field.
+func unknownKeyError(path string, key string, known []string) error {
+	err := fmt.Errorf("yamlx: unknown key %q", joinPath(path, key))
+	if match := closestMatch(key, known); match != "" {
+		err = fmt.Errorf("%w, did you mean %q?", err, match)
+	}
+	return err
+}
+
+// closestMatch returns the candidate closest to name, or "" if none of them
+// is close enough to be a likely typo.
+func closestMatch(name string, candidates []string) string {
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+	best, bestDistance := "", len(name)/2+1
+	for _, candidate := range sorted {
+		if d := levenshtein(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr := make([]int, len(rb)+1)
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// joinPath appends a key to a dotted document path.
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
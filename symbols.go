@@ -0,0 +1,126 @@
+package yamlx
+
+import (
+	"sort"
+	"strings"
+)
+
+// Symbol is a place in a document where an anchor is defined or used, by an
+// alias, merge key, loop range or expression.
+type Symbol struct {
+	Name       string // The anchor name, e.g. "db" for the alias *db.port
+	Line       int    // 1-based
+	Column     int    // 1-based column of the name itself, after any & or *
+	Definition bool
+}
+
+// Symbols returns the anchor definitions and uses in yamlx source, in
+// document order. Names in expressions that refer to a loop variable aren't
+// included, and neither are uses of anchors that are never defined before
+// them, except by aliases, which always name an anchor.
+func Symbols(data []byte) ([]Symbol, error) {
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	s := &symbolScanner{lines: strings.Split(string(data), "\n"), defined: make(map[string]bool)}
+	s.walk(doc.Nodes)
+	// Definitions are recorded after the uses nested under them
+	sort.SliceStable(s.symbols, func(i, j int) bool {
+		a, b := s.symbols[i], s.symbols[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return s.symbols, nil
+}
+
+// symbolScanner holds the state of a single Symbols call.
+type symbolScanner struct {
+	lines   []string
+	symbols []Symbol
+	defined map[string]bool // Anchors defined so far
+	scopes  [][]string      // Variables of the loops around the current node
+}
+
+func (s *symbolScanner) walk(nodes []*Node) {
+	for _, node := range nodes {
+		line := s.lines[node.Line-1]
+		switch {
+		case node.Type == LOOP:
+			if strings.HasPrefix(node.Value, "*") {
+				s.alias(node, line, strings.TrimSpace(node.Value[1:]))
+			}
+			s.expressions(node, line)
+			var variables []string
+			for _, variable := range strings.Split(node.Key, ",") {
+				variables = append(variables, strings.TrimSpace(variable))
+			}
+			s.scopes = append(s.scopes, variables)
+			s.walk(node.Children)
+			s.scopes = s.scopes[:len(s.scopes)-1]
+			continue
+		case node.Alias != "":
+			s.alias(node, line, node.Alias)
+		case node.Type == LIST_ITEM && node.Key == "" && strings.HasPrefix(node.Value, "*"):
+			s.alias(node, line, node.Value[1:])
+		default:
+			s.expressions(node, line)
+		}
+		s.walk(node.Children)
+
+		anchor := node.Anchor
+		if node.Type == LIST_ITEM && node.Key == "" && strings.HasPrefix(node.Value, "&") {
+			anchor, _, _ = strings.Cut(node.Value[1:], " ")
+		}
+		if anchor != "" {
+			s.defined[anchor] = true
+			s.symbols = append(s.symbols, Symbol{anchor, node.Line, strings.Index(line, "&"+anchor) + 2, true})
+		}
+	}
+}
+
+func (s *symbolScanner) alias(node *Node, line string, name string) {
+	base, _, _ := strings.Cut(name, ".")
+	if s.inScope(base) {
+		return
+	}
+	s.symbols = append(s.symbols, Symbol{base, node.Line, strings.Index(line, "*"+name) + 2, false})
+}
+
+// expressions records the anchors the ${} expressions on a line refer to.
+func (s *symbolScanner) expressions(node *Node, line string) {
+	isAnchor := func(name string) bool {
+		base, _, _ := strings.Cut(name, ".")
+		return s.defined[name] || (s.defined[base] && !s.inScope(base))
+	}
+	for i := 0; i < len(line); {
+		start := strings.Index(line[i:], "${")
+		if start < 0 {
+			return
+		}
+		start += i + 2
+		end := expressionEnd(line, start)
+		if end < 0 {
+			return
+		}
+		for _, span := range anchorSpans(line[start:end], isAnchor) {
+			name := line[start+span[0] : start+span[1]]
+			if !s.defined[name] {
+				name, _, _ = strings.Cut(name, ".")
+			}
+			s.symbols = append(s.symbols, Symbol{name, node.Line, start + span[0] + 1, false})
+		}
+		i = end + 1
+	}
+}
+
+func (s *symbolScanner) inScope(name string) bool {
+	for _, scope := range s.scopes {
+		if containsString(scope, name) {
+			return true
+		}
+	}
+	return false
+}
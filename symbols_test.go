@@ -0,0 +1,32 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSymbols(t *testing.T) {
+	yamlContent := `environments: &environments [dev, prod]
+db: &db
+  port: 5432
+defaults: &defaults
+  retries: 3
+servers:
+  !for name in *environments:
+    - host: ${name}.example.com
+      port: ${db.port + 1}
+      db: *db
+      <<: *defaults
+`
+	symbols, err := Symbols([]byte(yamlContent))
+	assert.Nil(t, err)
+	assert.Equal(t, []Symbol{
+		{"environments", 1, 16, true},
+		{"db", 2, 6, true},
+		{"defaults", 4, 12, true},
+		{"environments", 7, 17, false},
+		{"db", 9, 15, false},
+		{"db", 10, 12, false},
+		{"defaults", 11, 12, false},
+	}, symbols)
+}
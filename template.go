@@ -0,0 +1,86 @@
+package yamlx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Template is a yamlx document that has been tokenized once, so it can be
+// evaluated many times, e.g. with different Vars.
+type Template struct {
+	tokens []*Token
+	opts   Options
+}
+
+// Compile tokenizes YAMLX data into a Template that evaluates with opts.
+func Compile(data []byte, opts Options) (*Template, error) {
+	tokens, err := Tokenize(strings.Split(string(data), "\n"), 0)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Strict {
+		if err := checkDuplicateKeys(tokens, ""); err != nil {
+			return nil, err
+		}
+	}
+	return &Template{tokens: tokens, opts: opts}, nil
+}
+
+// Execute evaluates the template and decodes the result into v, like
+// UnmarshalWithOptions.
+func (t *Template) Execute(v interface{}) error {
+	return t.ExecuteContext(context.Background(), v)
+}
+
+// ExecuteContext is like Execute but stops evaluating when ctx is done,
+// returning ctx.Err() along with the line it got to. The context is checked
+// on each loop iteration and function call; yamlx has no includes, so there
+// are none to check it on.
+func (t *Template) ExecuteContext(ctx context.Context, v interface{}) error {
+	result, p, err := t.evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	d := &decoder{opts: t.opts, anchors: p.anchors, functions: p.expressionFunctions()}
+	if err := d.mapToStruct(result.ToMap(), v, ""); err != nil {
+		return err
+	}
+	return validate(v, p.sources)
+}
+
+// evaluate parses the template's tokens, returning the parser along with the
+// result so callers can reach the anchors and sources it collected.
+func (t *Template) evaluate(ctx context.Context) (*OrderedMap, *parser, error) {
+	p := newParser(make(map[string]any))
+	p.ctx = ctx
+	p.filename = t.opts.Filename
+	p.trace = t.opts.Trace
+	p.limits = newLimiter(t.opts.Limits)
+	p.random = newRandom(t.opts.Seed, t.tokens)
+	p.vars = make(map[string]any, len(t.opts.Vars))
+	for name, value := range t.opts.Vars {
+		p.vars[name] = parseScalar(value)
+		p.define(name, p.vars[name])
+	}
+	result, err := p.parse(t.tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, p, nil
+}
+
+// checkContext returns the context's error, if it's done, along with the
+// source position evaluation got to.
+func (p *parser) checkContext(line int) error {
+	if p.ctx == nil {
+		return nil
+	}
+	if err := p.ctx.Err(); err != nil {
+		if p.filename != "" {
+			return fmt.Errorf("yamlx: %s:%d: %w", p.filename, line, err)
+		}
+		return fmt.Errorf("yamlx: line %d: %w", line, err)
+	}
+	return nil
+}
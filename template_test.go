@@ -0,0 +1,46 @@
+package yamlx
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	type Config struct {
+		Host string `yamlx:"host"`
+	}
+	tmpl, err := Compile([]byte("env: &env dev\nhost: ${env}.example.com"), Options{})
+	assert.Nil(t, err)
+
+	var config Config
+	assert.Nil(t, tmpl.Execute(&config))
+	assert.Equal(t, "dev.example.com", config.Host)
+
+	// A template can be executed again
+	config = Config{}
+	assert.Nil(t, tmpl.ExecuteContext(context.Background(), &config))
+	assert.Equal(t, "dev.example.com", config.Host)
+}
+
+func TestContextCancellation(t *testing.T) {
+	type Config struct {
+		Items []int `yamlx:"items"`
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	loop := "items:\n  !for i in [1..3]:\n    - ${i}"
+	var config Config
+	err := UnmarshalContext(ctx, []byte(loop), &config, Options{Filename: "config.yaml"})
+	assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+	assert.Equal(t, "yamlx: config.yaml:3: context canceled", err.Error())
+
+	err = UnmarshalContext(ctx, []byte(`host: ${upper("a")}`), &config, Options{})
+	assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+	assert.Equal(t, "yamlx: line 1: context canceled", err.Error())
+
+	assert.Nil(t, UnmarshalContext(context.Background(), []byte(loop), &config, Options{}))
+	assert.Equal(t, []int{1, 2, 3}, config.Items)
+}
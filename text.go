@@ -0,0 +1,327 @@
+package yamlx
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stringArg checks that a function got count arguments, the first of them a
+// string, and returns it.
+func stringArg(name string, args []any, count int) (string, error) {
+	if len(args) != count {
+		return "", fmt.Errorf("%s function requires %d arguments", name, count)
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return "", fmt.Errorf("%s function requires a string", name)
+	}
+	return s, nil
+}
+
+// stringArgs returns the arguments of a function that takes only strings.
+func stringArgs(name string, args []any, count int) ([]string, error) {
+	if len(args) != count {
+		return nil, fmt.Errorf("%s function requires %d arguments", name, count)
+	}
+	result := make([]string, count)
+	for i, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s function requires string arguments", name)
+		}
+		result[i] = s
+	}
+	return result, nil
+}
+
+// textValue writes a value as it appears in output, without a decimal
+// point for whole numbers.
+func textValue(value any) string {
+	if f, ok := value.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func format(args ...any) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("format function requires a format string")
+	}
+	layout, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("format function requires a format string")
+	}
+	values := append([]any(nil), args[1:]...)
+	// Numbers are all float64 in expressions, so convert them to suit the
+	// verbs they're formatted with
+	verb := 0
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			continue
+		}
+		j := i + 1
+		for j < len(layout) && strings.IndexByte("+-# 0123456789.", layout[j]) >= 0 {
+			j++
+		}
+		if j == len(layout) {
+			break
+		}
+		if layout[j] == '%' {
+			i = j
+			continue
+		}
+		if verb < len(values) {
+			if f, ok := values[verb].(float64); ok && strings.IndexByte("dxXobcU", layout[j]) >= 0 {
+				values[verb] = int64(f)
+			} else if n, ok := values[verb].(int64); ok && strings.IndexByte("eEfFgG", layout[j]) >= 0 {
+				values[verb] = float64(n)
+			}
+		}
+		verb++
+		i = j
+	}
+	return fmt.Sprintf(layout, values...), nil
+}
+
+func pad(sc scope, args ...any) (any, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("pad function requires 2 or 3 arguments")
+	}
+	width, ok := args[1].(float64)
+	if !ok {
+		return nil, fmt.Errorf("pad function requires a numeric width")
+	}
+	fill := " "
+	if len(args) == 3 {
+		fill, ok = args[2].(string)
+		if !ok || utf8.RuneCountInString(fill) != 1 {
+			return nil, fmt.Errorf("pad function requires a single padding character")
+		}
+	}
+	if err := checkLength("pad", "width", math.Abs(width)); err != nil {
+		return nil, err
+	}
+	s := textValue(args[0])
+	missing := int(math.Abs(width)) - utf8.RuneCountInString(s)
+	if missing <= 0 {
+		return s, nil
+	}
+	if sc.checkSize != nil {
+		if err := sc.checkSize(len(s) + missing*len(fill)); err != nil {
+			return nil, err
+		}
+	}
+	if width < 0 {
+		return s + strings.Repeat(fill, missing), nil
+	}
+	return strings.Repeat(fill, missing) + s, nil
+}
+
+func compileRegex(name string, pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s function requires a valid regular expression: %w", name, err)
+	}
+	return re, nil
+}
+
+func regexMatch(args ...any) (any, error) {
+	strs, err := stringArgs("regex_match", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	re, err := compileRegex("regex_match", strs[1])
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(strs[0]), nil
+}
+
+func regexReplace(args ...any) (any, error) {
+	strs, err := stringArgs("regex_replace", args, 3)
+	if err != nil {
+		return nil, err
+	}
+	re, err := compileRegex("regex_replace", strs[1])
+	if err != nil {
+		return nil, err
+	}
+	return re.ReplaceAllString(strs[0], strs[2]), nil
+}
+
+func regexFindAll(args ...any) (any, error) {
+	strs, err := stringArgs("regex_find_all", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	re, err := compileRegex("regex_find_all", strs[1])
+	if err != nil {
+		return nil, err
+	}
+	result := List{}
+	for _, match := range re.FindAllString(strs[0], -1) {
+		result = append(result, match)
+	}
+	return result, nil
+}
+
+func split(args ...any) (any, error) {
+	strs, err := stringArgs("split", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	result := List{}
+	for _, part := range strings.Split(strs[0], strs[1]) {
+		result = append(result, part)
+	}
+	return result, nil
+}
+
+func indent(sc scope, args ...any) (any, error) {
+	s, err := stringArg("indent", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	width, ok := args[1].(float64)
+	if !ok {
+		return nil, fmt.Errorf("indent function requires a numeric width")
+	}
+	if err := checkLength("indent", "width", width); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(s, "\n")
+	indented := 0
+	for _, line := range lines {
+		if line != "" {
+			indented++
+		}
+	}
+	added := int(width) * indented
+	if added > maxLength {
+		return nil, fmt.Errorf("indent function would add more than %d spaces", maxLength)
+	}
+	if sc.checkSize != nil {
+		if err := sc.checkSize(len(s) + added); err != nil {
+			return nil, err
+		}
+	}
+	prefix := strings.Repeat(" ", int(width))
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func quote(args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("quote function requires 1 argument")
+	}
+	return strconv.Quote(textValue(args[0])), nil
+}
+
+func truncate(args ...any) (any, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("truncate function requires 2 or 3 arguments")
+	}
+	s, ok := args[0].(string)
+	length, isNumber := args[1].(float64)
+	if !ok || !isNumber || !(length >= 0) {
+		return nil, fmt.Errorf("truncate function requires a string and a non-negative length")
+	}
+	suffix := ""
+	if len(args) == 3 {
+		if suffix, ok = args[2].(string); !ok {
+			return nil, fmt.Errorf("truncate function requires a string suffix")
+		}
+	}
+	runes := []rune(s)
+	// Compared before converting, as a huge length doesn't fit in an int
+	if float64(len(runes)) <= length {
+		return s, nil
+	}
+	// The suffix counts towards the length, unless it wouldn't fit at all
+	keep := int(length) - utf8.RuneCountInString(suffix)
+	if keep < 0 {
+		return string(runes[:int(length)]), nil
+	}
+	return string(runes[:keep]) + suffix, nil
+}
+
+// words splits a string into words at spaces, punctuation and changes from
+// lower to upper case, so "httpServer_port" is http, Server and port.
+func words(s string) []string {
+	var result []string
+	var word []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				result = append(result, string(word))
+				word = nil
+			}
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// Split "fooBar" and the "P" of "HTTPPort", but not "HTTP"
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				result = append(result, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		result = append(result, string(word))
+	}
+	return result
+}
+
+// joinWords converts the words of a string to lowercase and joins them.
+func joinWords(name string, args []any, separator string) (any, error) {
+	s, err := stringArg(name, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	parts := words(s)
+	for i, word := range parts {
+		parts[i] = strings.ToLower(word)
+	}
+	return strings.Join(parts, separator), nil
+}
+
+func slugify(args ...any) (any, error) {
+	return joinWords("slugify", args, "-")
+}
+
+func snake(args ...any) (any, error) {
+	return joinWords("snake", args, "_")
+}
+
+func kebab(args ...any) (any, error) {
+	return joinWords("kebab", args, "-")
+}
+
+func camel(args ...any) (any, error) {
+	s, err := stringArg("camel", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for i, word := range words(s) {
+		runes := []rune(strings.ToLower(word))
+		if i > 0 {
+			runes[0] = unicode.ToUpper(runes[0])
+		}
+		b.WriteString(string(runes))
+	}
+	return b.String(), nil
+}
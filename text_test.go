@@ -0,0 +1,104 @@
+package yamlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStringFunctions(t *testing.T) {
+	tests := map[string]any{
+		`format("%s-%03d", "web", 7)`: "web-007",
+		`printf("%.1f%%", 12.34)`:     "12.3%",
+		`format("%x", 255)`:           "ff",
+		`pad(7, 3, "0")`:              "007",
+		`pad("ab", -4)`:               "ab  ",
+		`pad("abcdef", 3)`:            "abcdef",
+		`regex_match("web-01.example.com", "^web-[0-9]+\\.")`:   true,
+		`regex_match("db-01", "^web")`:                          false,
+		`regex_replace("web-01", "([a-z]+)-([0-9]+)", "$2-$1")`: "01-web",
+		`regex_find_all("a1b22c333", "[0-9]+")`:                 []any{"1", "22", "333"},
+		`split("a,b,c", ",")`:                                   []any{"a", "b", "c"},
+		"indent(\"a\nb\", 2)":                                   "  a\n  b",
+		`quote("say \"hi\"")`:                                   `"say \"hi\""`,
+		`truncate("hello world", 8, "...")`:                     "hello...",
+		`truncate("hello", 8, "...")`:                           "hello",
+		`truncate("héllo", 2)`:                                  "hé",
+		`slugify("Hello, World! 2024")`:                         "hello-world-2024",
+		`camel("http server_port")`:                             "httpServerPort",
+		`snake("HTTPServerPort")`:                               "http_server_port",
+		`kebab("myApp Name")`:                                   "my-app-name",
+		`len("héllo")`:                                          float64(5),
+		`substr("héllo", 1, 3)`:                                 "él",
+		`substr("hello", 3)`:                                    "lo",
+		`substr("hello", 2, 99)`:                                "llo",
+		`substr("hello", 4, 1)`:                                 "",
+	}
+	for expression, expected := range tests {
+		result, err := Eval([]byte("a: 1"), expression, Options{})
+		assert.NoError(t, err, expression)
+		assert.Equal(t, expected, result, expression)
+	}
+
+	for _, expression := range []string{`regex_match("a", "(")`, `pad("a", 3, "ab")`, `truncate(1, 2)`, `camel(1)`} {
+		_, err := Eval([]byte("a: 1"), expression, Options{})
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestTruncateLength(t *testing.T) {
+	out, err := RenderJSON([]byte(`a: ${truncate("abcdef", 100000000000000000000)}`), Options{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":"abcdef"}`, string(out))
+
+	_, err = RenderJSON([]byte(`a: ${truncate("abcdef", -1)}`), Options{})
+	assert.ErrorContains(t, err, "truncate function requires a string and a non-negative length")
+}
+
+func TestPaddingWidths(t *testing.T) {
+	for _, expression := range []string{
+		`indent("x", 9000000000000000000)`,
+		`indent("x", -1)`,
+		`pad("x", 9000000000000000000)`,
+		`pad("x", -9000000000000000000)`,
+	} {
+		_, err := RenderJSON([]byte("a: ${"+expression+"}"), Options{Limits: Limits{MaxStringSize: 100}})
+		assert.ErrorContains(t, err, "function requires a width between 0", expression)
+	}
+
+	for _, expression := range []string{`indent("x", 200)`, `pad("x", 200)`, `pad("x", -200)`} {
+		_, err := RenderJSON([]byte("a: ${"+expression+"}"), Options{Limits: Limits{MaxStringSize: 100}})
+		assert.ErrorIs(t, err, ErrLimitExceeded, expression)
+	}
+}
+
+func TestStringResultsStayStrings(t *testing.T) {
+	out, err := RenderJSON([]byte(`
+padded: ${pad("42", 5, "0")}
+formatted: ${format("%05d", 42)}
+flag: ${lower("TRUE")}
+sum: ${2 + 3}
+mixed: id-${pad("7", 3, "0")}
+`), Options{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"padded":"00042","formatted":"00042","flag":true,"sum":5,"mixed":"id-007"}`, string(out))
+}
+
+func TestReferencesAreReadAsScalars(t *testing.T) {
+	type Server struct {
+		Port int `yamlx:"port"`
+	}
+	type Config struct {
+		Num     int      `yamlx:"copy"`
+		Servers []Server `yamlx:"servers"`
+	}
+	var config Config
+	err := Unmarshal([]byte(`
+num: &num "42"
+copy: ${num}
+servers:
+  !for p in [80, 443]:
+    - port: ${p}
+`), &config)
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Num: 42, Servers: []Server{{80}, {443}}}, config)
+}
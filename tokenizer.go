@@ -2,8 +2,9 @@ package yamlx
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -38,10 +39,11 @@ type Token struct {
 	Literal     string
 	Children    Tokens // To hold nested tokens
 	Attachments Tokens // To hold attachments
+	Line        int    // Source line the token was read from (1-based)
 }
 
 func NewToken(t Type, literal string) *Token {
-	return &Token{t, literal, nil, nil}
+	return &Token{t, literal, nil, nil, 0}
 }
 
 func (t Token) String() string {
@@ -51,112 +53,98 @@ func (t Token) String() string {
 }
 
 func (t Token) Print(prefix string) {
-	fmt.Println(prefix + t.String())
+	t.Fprint(os.Stdout, prefix)
+}
+
+// Fprint is like Print but writes to w.
+func (t Token) Fprint(w io.Writer, prefix string) {
+	fmt.Fprintln(w, prefix+t.String())
 	for _, attachment := range t.Attachments {
-		attachment.Print(prefix + " ")
+		attachment.Fprint(w, prefix+" ")
 	}
 	for _, child := range t.Children {
-		child.Print(prefix + "\t")
+		child.Fprint(w, prefix+"\t")
 	}
 }
 
 func Tokenize(lines []string, currentLevel int) ([]*Token, error) {
+	return tokenize(lines, currentLevel, 1)
+}
+
+// tokenize does the work of Tokenize, where firstLine is the source line
+// number of lines[0].
+func tokenize(lines []string, currentLevel int, firstLine int) ([]*Token, error) {
 	var tokens []*Token
 	for i := 0; i < len(lines); i++ {
-		line := lines[i]
-
-		// Remove comments
-		if strings.Contains(line, "#") {
-			line = strings.Split(line, "#")[0]
-		}
-
-		// Trim spaces
-		line = strings.TrimSpace(line)
-
-		// Skip empty lines
-		if len(line) == 0 {
+		// Skip empty lines and comments
+		if content, _ := splitComment(strings.TrimSpace(lines[i])); content == "" {
 			continue
 		}
+		node, err := parseNodeLine(lines[i])
+		if err != nil {
+			return nil, err
+		}
 
 		// Determine the token type based on the line
 		var parentToken *Token
-		if strings.Contains(line, "!for") {
-			// for loop
-			// format is: !for <var> in <range>:
-
-			// get text between !for and :
-			startIndex := strings.Index(line, "!for")
-			endIndex := strings.Index(line, ":")
-			contents := strings.TrimSpace(line[startIndex+4 : endIndex])
-			// split by in
-			values := strings.Split(contents, " in ")
-			if len(values) != 2 {
-				return nil, fmt.Errorf("invalid for loop: %s", line)
-			}
-			variable := strings.TrimSpace(values[0])
-			parentToken = NewToken(LOOP, variable)
-			rangeString := strings.TrimSpace(values[1])
+		switch node.Type {
+		case LOOP:
+			parentToken = NewToken(LOOP, node.Key)
+			rangeString := node.Value
 			if strings.HasPrefix(rangeString, "[") {
 				// get the string between brackets
-				rangeString = strings.Trim(strings.TrimSpace(rangeString), "[]")
+				rangeString = strings.Trim(rangeString, "[]")
 			}
 			parentToken.Attachments = []*Token{NewToken(LOOP_RANGE, rangeString)}
 			tokens = append(tokens, parentToken)
-		} else if strings.HasPrefix(line, "- ") {
-			parts := strings.SplitN(line[2:], ":", 2)
-			parentToken = NewToken(LIST_ITEM, strings.TrimSpace(parts[0]))
-			if len(parts) > 1 && len(strings.TrimSpace(parts[1])) > 0 {
-				attachment := handleKeyValueString(parentToken, strings.TrimSpace(parts[1]))
-				if attachment != nil {
-					parentToken.Attachments = []*Token{attachment}
+		case LIST_ITEM:
+			if node.Key == "" {
+				parentToken = NewToken(LIST_ITEM, node.Value)
+			} else {
+				parentToken = NewToken(LIST_ITEM, node.Key)
+				if value := node.valueText(); value != "" {
+					attachment := handleKeyValueString(parentToken, value)
+					if attachment != nil {
+						parentToken.Attachments = []*Token{attachment}
+					}
 				}
 			}
 			tokens = append(tokens, parentToken)
-		} else if strings.HasPrefix(line, "<<: *") {
-			parts := strings.SplitN(line, "<<: *", 2)
-			tokens = append(tokens, NewToken(MERGE_KEY, strings.TrimSpace(parts[1])))
-		} else if strings.Contains(line, ": &") {
-			parts := strings.SplitN(line, ": &", 2)
-			parentToken = NewToken(KEY, strings.TrimSpace(parts[0]))
-			tokens = append(tokens, parentToken)
-			value := strings.TrimSpace(parts[1])
-			values := strings.Split(value, " ")
-			attachments := make([]*Token, 0)
-			if len(values) > 1 {
-				attachments = append(attachments, NewToken(ANCHOR, values[0]))
-				attachment := handleKeyValueString(parentToken, strings.Join(values[1:], " "))
-				if attachment != nil {
-					attachments = append(attachments, attachment)
+		case MERGE_KEY:
+			tokens = append(tokens, NewToken(MERGE_KEY, node.Alias))
+		case KEY:
+			token := NewToken(KEY, node.Key)
+			tokens = append(tokens, token)
+			if node.Anchor != "" {
+				parentToken = token
+				attachments := []*Token{NewToken(ANCHOR, node.Anchor)}
+				if value := strings.TrimPrefix(node.valueText(), "&"+node.Anchor); value != "" {
+					attachment := handleKeyValueString(parentToken, value)
+					if attachment != nil {
+						attachments = append(attachments, attachment)
+					}
 				}
+				parentToken.Attachments = attachments
+			} else if node.Alias != "" {
+				token.Attachments = []*Token{NewToken(ALIAS, node.Alias)}
 			} else {
-				attachments = append(attachments, NewToken(ANCHOR, value))
-			}
-			parentToken.Attachments = attachments
-		} else if strings.Contains(line, ": *") {
-			parts := strings.SplitN(line, ": *", 2)
-			token := NewToken(KEY, strings.TrimSpace(parts[0]))
-			token.Attachments = []*Token{NewToken(ALIAS, strings.TrimSpace(parts[1]))}
-			tokens = append(tokens, token)
-		} else if strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			parentToken = NewToken(KEY, strings.TrimSpace(parts[0]))
-			if len(parts) > 1 && len(strings.TrimSpace(parts[1])) > 0 {
-				attachment := handleKeyValueString(parentToken, strings.TrimSpace(parts[1]))
-				if attachment != nil {
-					parentToken.Attachments = []*Token{attachment}
+				parentToken = token
+				if node.Value != "" {
+					attachment := handleKeyValueString(parentToken, node.Value)
+					if attachment != nil {
+						parentToken.Attachments = []*Token{attachment}
+					}
 				}
 			}
-			tokens = append(tokens, parentToken)
-		} else {
-			return nil, fmt.Errorf("invalid line: %s", line)
 		}
+		tokens[len(tokens)-1].setLine(firstLine + i)
 
 		if i < len(lines)-1 {
 			nextIndent := countLeadingSpaces(lines[i+1])
 			if parentToken != nil && nextIndent > currentLevel {
 				// Process nested lines
 				end := findEndOfBlock(lines, i+1, currentLevel)
-				nestedTokens, err := Tokenize(lines[i+1:end], nextIndent)
+				nestedTokens, err := tokenize(lines[i+1:end], nextIndent, firstLine+i+1)
 				if err != nil {
 					return nil, err
 				}
@@ -168,6 +156,18 @@ func Tokenize(lines []string, currentLevel int) ([]*Token, error) {
 	return tokens, nil
 }
 
+// setLine records the source line on a token and everything created from
+// the same line.
+func (t *Token) setLine(line int) {
+	t.Line = line
+	for _, attachment := range t.Attachments {
+		attachment.setLine(line)
+	}
+	for _, child := range t.Children {
+		child.setLine(line)
+	}
+}
+
 func handleKeyValueString(parentToken *Token, value string) *Token {
 	// returns attachment if necessary
 	re := regexp.MustCompile(`(\$\{[^}]*\}|"[^"]*")|(\*)`)
@@ -188,17 +188,9 @@ func handleKeyValueString(parentToken *Token, value string) *Token {
 	} else if strings.HasPrefix(strings.TrimSpace(value), "[") {
 		// get the string between brackets
 		contents := strings.Trim(strings.TrimSpace(value), "[]")
-		// split by ..
-		values := strings.Split(contents, "..")
-		if len(values) == 2 {
-			start, end := strings.TrimSpace(values[0]), strings.TrimSpace(values[1])
-			startInt, _ := strconv.ParseInt(start, 10, 64)
-			endInt, _ := strconv.ParseInt(end, 10, 64)
-			children := make([]*Token, 0)
-			for i := startInt; i <= endInt; i++ {
-				children = append(children, NewToken(LIST_ITEM, fmt.Sprintf("%d", i)))
-			}
-			parentToken.Children = children
+		if strings.Count(contents, "..") == 1 {
+			// Ranges are expanded when parsed, so their size can be limited
+			return NewToken(LOOP_RANGE, strings.TrimSpace(contents))
 		} else {
 			// split by comma
 			values := strings.Split(contents, ",")
@@ -0,0 +1,137 @@
+package yamlx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// TraceKind is the kind of an evaluation step.
+type TraceKind string
+
+const (
+	TraceAnchor     TraceKind = "anchor"     // An anchor was defined
+	TraceAlias      TraceKind = "alias"      // An alias was resolved
+	TraceExpression TraceKind = "expression" // A ${} expression was evaluated
+	TraceLoop       TraceKind = "loop"       // A !for loop was expanded
+	TraceIteration  TraceKind = "iteration"  // One pass through a loop body
+	TraceMerge      TraceKind = "merge"      // A << merge key was applied
+)
+
+// Trace records the steps of an evaluation. Set Options.Trace to a Trace to
+// have evaluation add its steps to it.
+type Trace struct {
+	Steps []*TraceStep `json:"steps"`
+}
+
+// TraceStep is a single step of an evaluation. Loops hold a step for each
+// iteration, which hold the steps of the loop body.
+type TraceStep struct {
+	Kind       TraceKind      `json:"kind"`
+	Line       int            `json:"line,omitempty"`
+	Path       string         `json:"path,omitempty"`       // The output path the step produced
+	Name       string         `json:"name,omitempty"`       // The anchor, alias or merged anchor, or the variables of a loop
+	Expression string         `json:"expression,omitempty"` // An expression, or the range of a loop
+	Inputs     map[string]any `json:"inputs,omitempty"`     // Anchors an expression read, or the variables of an iteration
+	Result     any            `json:"result,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Steps      []*TraceStep   `json:"steps,omitempty"`
+}
+
+func (s TraceStep) String() string {
+	var detail string
+	switch s.Kind {
+	case TraceAnchor:
+		detail = fmt.Sprintf("&%s = %s", s.Name, inlineValue(s.Result))
+	case TraceAlias:
+		detail = fmt.Sprintf("*%s = %s", s.Name, inlineValue(s.Result))
+	case TraceMerge:
+		detail = fmt.Sprintf("<<: *%s = %s", s.Name, inlineValue(s.Result))
+	case TraceLoop:
+		detail = fmt.Sprintf("%s in %s = %s", s.Name, s.Expression, inlineValue(s.Result))
+	case TraceIteration:
+		detail = formatInputs(s.Inputs)
+	case TraceExpression:
+		detail = "${" + s.Expression + "}"
+		if len(s.Inputs) > 0 {
+			detail += " with " + formatInputs(s.Inputs)
+		}
+		if s.Error != "" {
+			detail += " failed: " + s.Error
+		} else {
+			detail += " = " + inlineValue(s.Result)
+		}
+	}
+	result := fmt.Sprintf("%s: %s", strings.ToUpper(string(s.Kind)), detail)
+	if s.Path != "" {
+		result += " at " + s.Path
+	}
+	if s.Line > 0 {
+		result += fmt.Sprintf(" (line %d)", s.Line)
+	}
+	return result
+}
+
+// Print writes the trace as a tree, like Token.Print.
+func (t *Trace) Print() {
+	t.Fprint(os.Stdout)
+}
+
+// Fprint is like Print but writes to w.
+func (t *Trace) Fprint(w io.Writer) {
+	for _, step := range t.Steps {
+		step.Fprint(w, "")
+	}
+}
+
+// Fprint writes a step and the steps under it, indenting them with tabs.
+func (s *TraceStep) Fprint(w io.Writer, prefix string) {
+	fmt.Fprintln(w, prefix+s.String())
+	for _, step := range s.Steps {
+		step.Fprint(w, prefix+"\t")
+	}
+}
+
+func formatInputs(inputs map[string]any) string {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + inlineValue(inputs[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// traceStep adds a step to the trace, inside the current loop iteration if
+// there is one.
+func (p *parser) traceStep(step *TraceStep) {
+	if p.steps == nil {
+		p.steps = &p.trace.Steps
+	}
+	*p.steps = append(*p.steps, step)
+}
+
+// traceExpression records the evaluation of an expression along with the
+// anchors it read.
+func (p *parser) traceExpression(expression string, line int, path string, result any, err error) {
+	step := &TraceStep{Kind: TraceExpression, Line: line, Path: path, Expression: expression, Result: result}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	for _, span := range anchorSpans(expression, func(name string) bool {
+		_, ok := p.anchors[name]
+		return ok
+	}) {
+		if step.Inputs == nil {
+			step.Inputs = make(map[string]any)
+		}
+		name := expression[span[0]:span[1]]
+		step.Inputs[name] = p.anchors[name]
+	}
+	p.traceStep(step)
+}
@@ -0,0 +1,39 @@
+package yamlx
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	yamlContent := `envs: &envs [dev, prod]
+defaults: &defaults
+  port: 22
+servers:
+  !for idx, env in *envs:
+    - host: ${env}.example.com
+      index: ${idx + 1}
+custom:
+  <<: *defaults
+  env: *envs
+`
+	trace := &Trace{}
+	_, err := RenderJSON([]byte(yamlContent), Options{Trace: trace})
+	assert.Nil(t, err)
+
+	var b bytes.Buffer
+	trace.Fprint(&b)
+	assert.Equal(t, `ANCHOR: &envs = ["dev","prod"] at envs (line 1)
+ANCHOR: &defaults = {"port":22} at defaults (line 2)
+LOOP: idx, env in *envs = ["dev","prod"] at servers (line 5)
+	ITERATION: env="dev", idx=0 at servers[0] (line 6)
+		EXPRESSION: ${env} with env="dev" = "dev" at servers[0].host (line 6)
+		EXPRESSION: ${idx + 1} with idx=0 = 1 at servers[0].index (line 7)
+	ITERATION: env="prod", idx=1 at servers[1] (line 6)
+		EXPRESSION: ${env} with env="prod" = "prod" at servers[1].host (line 6)
+		EXPRESSION: ${idx + 1} with idx=1 = 2 at servers[1].index (line 7)
+MERGE: <<: *defaults = {"port":22} at custom (line 9)
+ALIAS: *envs = ["dev","prod"] at custom.env (line 10)
+`, b.String())
+}
@@ -0,0 +1,232 @@
+package yamlx
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator is implemented by types that check their own decoded values.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a single validation failure.
+type FieldError struct {
+	Path    string // Document path of the value, e.g. servers[0].port
+	Line    int    // Source line of the value, or 0 if it didn't come from the document
+	Message string
+}
+
+func (e FieldError) Error() string {
+	location := e.Path
+	if location == "" {
+		location = "value"
+	}
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s (line %d)", location, e.Line)
+	}
+	return location + ": " + e.Message
+}
+
+// ValidationError collects every failure found while validating a value.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		lines[i] = "  " + fieldErr.Error()
+	}
+	return "yamlx: validation failed:\n" + strings.Join(lines, "\n")
+}
+
+// Validate checks a value against its validate struct tags, e.g.
+// `validate:"min=1,max=65535"`, and calls the Validate method of any value
+// that implements Validator. Unmarshal runs it after decoding.
+func Validate(v interface{}) error {
+	return validate(v, nil)
+}
+
+// validate runs the validation pass, using sources to report the source line
+// of each failing path.
+func validate(v any, sources SourceMap) error {
+	vl := &validator{sources: sources}
+	vl.check(reflect.ValueOf(v), "")
+	if len(vl.errors) > 0 {
+		return &ValidationError{Errors: vl.errors}
+	}
+	return nil
+}
+
+// validator walks a value, collecting failures.
+type validator struct {
+	sources SourceMap
+	errors  []FieldError
+}
+
+func (vl *validator) fail(path string, message string) {
+	vl.errors = append(vl.errors, FieldError{Path: path, Line: vl.sources[path].Line, Message: message})
+}
+
+// check validates a value and everything it contains.
+func (vl *validator) check(val reflect.Value, path string) {
+	if !val.IsValid() {
+		return
+	}
+	if val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return
+		}
+		// The element of a pointer is addressable, so its pointer methods
+		// are still found below
+		vl.check(val.Elem(), path)
+		return
+	}
+	if val.CanAddr() {
+		vl.callValidate(val.Addr(), path)
+	} else {
+		vl.callValidate(val, path)
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		typ := val.Type()
+		for i := 0; i < val.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldPath := joinPath(path, parseFieldTag(field).Name)
+			if rules := field.Tag.Get("validate"); rules != "" {
+				vl.checkRules(val.Field(i), fieldPath, rules)
+			}
+			vl.check(val.Field(i), fieldPath)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			vl.check(val.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		keys := val.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			vl.check(val.MapIndex(key), joinPath(path, fmt.Sprint(key.Interface())))
+		}
+	}
+}
+
+// callValidate calls the Validate method of a value if it has one.
+func (vl *validator) callValidate(val reflect.Value, path string) {
+	if !val.CanInterface() {
+		return
+	}
+	if v, ok := val.Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			vl.fail(path, err.Error())
+		}
+	}
+}
+
+// checkRules applies the comma separated rules of a validate tag to a value.
+func (vl *validator) checkRules(val reflect.Value, path string, rules string) {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return
+		}
+		val = val.Elem()
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "omitempty" {
+			if val.IsZero() {
+				return
+			}
+			continue
+		}
+		if message := checkRule(name, param, val); message != "" {
+			vl.fail(path, message)
+		}
+	}
+}
+
+var hostnameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// checkRule applies a single rule, returning a description of the failure
+// or "" if the value passes.
+func checkRule(name string, param string, val reflect.Value) string {
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return fmt.Sprintf("invalid %s rule: %s", name, param)
+		}
+		n, isLength, ok := measure(val)
+		if !ok {
+			return fmt.Sprintf("%s rule doesn't apply to %s", name, val.Kind())
+		}
+		what := "must be"
+		if isLength {
+			what = "length must be"
+		}
+		if name == "min" && n < limit {
+			return fmt.Sprintf("%s at least %s", what, param)
+		}
+		if name == "max" && n > limit {
+			return fmt.Sprintf("%s at most %s", what, param)
+		}
+	case "oneof":
+		options := strings.Fields(param)
+		s := fmt.Sprint(val.Interface())
+		for _, option := range options {
+			if s == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(options, ", "), s)
+	case "hostname":
+		s, ok := val.Interface().(string)
+		if !ok || len(s) > 253 || !hostnameRegex.MatchString(s) {
+			return fmt.Sprintf("must be a valid hostname, got %q", fmt.Sprint(val.Interface()))
+		}
+	case "cidr":
+		s, _ := val.Interface().(string)
+		if _, _, err := net.ParseCIDR(s); err != nil {
+			return fmt.Sprintf("must be a valid CIDR, got %q", fmt.Sprint(val.Interface()))
+		}
+	case "ip":
+		s, _ := val.Interface().(string)
+		if net.ParseIP(s) == nil {
+			return fmt.Sprintf("must be a valid IP address, got %q", fmt.Sprint(val.Interface()))
+		}
+	default:
+		return fmt.Sprintf("unknown validation rule %q", name)
+	}
+	return ""
+}
+
+// measure returns the number min and max compare against: the value of a
+// number, or the length of a string, slice or map.
+func measure(val reflect.Value) (n float64, isLength bool, ok bool) {
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), false, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(val.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(val.Len()), true, true
+	}
+	return 0, false, false
+}
@@ -0,0 +1,64 @@
+package yamlx
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ValidatedServer struct {
+	Name string `yamlx:"name" validate:"hostname"`
+	Port int    `yamlx:"port" validate:"min=1,max=65535"`
+	Env  string `yamlx:"env" validate:"oneof=dev prod"`
+	Net  string `yamlx:"net" validate:"omitempty,cidr"`
+}
+
+type ValidatedConfig struct {
+	Servers []ValidatedServer `yamlx:"servers"`
+}
+
+func (c ValidatedConfig) Validate() error {
+	if len(c.Servers) > 2 {
+		return errors.New("at most 2 servers are supported")
+	}
+	return nil
+}
+
+func TestUnmarshalValidation(t *testing.T) {
+	yamlContent := `
+servers:
+  - name: web
+    port: 22
+    env: dev
+    net: 10.0.0.0/16
+  - name: db_1
+    port: 70000
+    env: staging
+    net: 10.0.0.0
+  - name: cache
+    port: 6379
+    env: prod
+`
+	var result ValidatedConfig
+	err := Unmarshal([]byte(yamlContent), &result)
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []FieldError{
+		{Path: "", Line: 0, Message: "at most 2 servers are supported"},
+		{Path: "servers[1].name", Line: 7, Message: `must be a valid hostname, got "db_1"`},
+		{Path: "servers[1].port", Line: 8, Message: "must be at most 65535"},
+		{Path: "servers[1].env", Line: 9, Message: `must be one of dev, prod, got "staging"`},
+		{Path: "servers[1].net", Line: 10, Message: `must be a valid CIDR, got "10.0.0.0"`},
+	}, validationErr.Errors)
+	assert.Contains(t, err.Error(), "servers[1].port (line 8): must be at most 65535")
+}
+
+func TestValidate(t *testing.T) {
+	server := ValidatedServer{Name: "web", Port: 0, Env: "prod"}
+	err := Validate(&server)
+	assert.EqualError(t, err, "yamlx: validation failed:\n  port: must be at least 1")
+
+	server.Port = 80
+	assert.NoError(t, Validate(server))
+}
@@ -0,0 +1,163 @@
+package yamlx
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often a Watcher checks its file when no
+// interval is given.
+const DefaultWatchInterval = time.Second
+
+// Watcher reloads a file whenever it changes on disk. Each version that
+// loads successfully is delivered on Updates; versions that fail are
+// reported on Errors, and Current keeps returning the last good one. Only the
+// file itself is watched: yamlx has no includes, so there are no other files
+// a document could depend on.
+type Watcher[T any] struct {
+	path     string
+	interval time.Duration
+	load     func([]byte) (T, error)
+
+	mu      sync.Mutex
+	current T
+
+	updates chan T
+	errors  chan error
+	done    chan struct{}
+	closed  sync.Once
+}
+
+// Watch loads a yamlx file into a new T, which must be a struct, the same way
+// UnmarshalWithOptions does, including validation. It fails if the first load
+// does, and then polls the file every interval for changes.
+func Watch[T any](path string, interval time.Duration, opts Options) (*Watcher[T], error) {
+	return WatchFunc(path, interval, func(data []byte) (T, error) {
+		var v T
+		err := UnmarshalWithOptions(data, &v, opts)
+		return v, err
+	})
+}
+
+// WatchFunc is like Watch but loads the file with a function of your own, e.g.
+// one that renders it.
+func WatchFunc[T any](path string, interval time.Duration, load func(data []byte) (T, error)) (*Watcher[T], error) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	current, err := load(data)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher[T]{
+		path:     path,
+		interval: interval,
+		load:     load,
+		current:  current,
+		updates:  make(chan T, 1),
+		errors:   make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+	go w.poll(info, data)
+	return w, nil
+}
+
+// Current returns the last version of the file that loaded successfully.
+func (w *Watcher[T]) Current() T {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Updates delivers each new version of the file that loads successfully.
+// Only the latest version is kept if nobody is receiving.
+func (w *Watcher[T]) Updates() <-chan T {
+	return w.updates
+}
+
+// Errors reports versions of the file that fail to load. Only the latest
+// error is kept if nobody is receiving.
+func (w *Watcher[T]) Errors() <-chan error {
+	return w.errors
+}
+
+// Close stops watching the file.
+func (w *Watcher[T]) Close() {
+	w.closed.Do(func() { close(w.done) })
+}
+
+func (w *Watcher[T]) poll(last os.FileInfo, lastData []byte) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(w.path)
+		if err != nil {
+			w.report(err)
+			continue
+		}
+		if info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+		data, err := os.ReadFile(w.path)
+		if err != nil {
+			w.report(err)
+			continue
+		}
+		if bytes.Equal(data, lastData) {
+			continue
+		}
+		lastData = data
+		value, err := w.load(data)
+		if err != nil {
+			w.report(err)
+			continue
+		}
+
+		w.mu.Lock()
+		w.current = value
+		w.mu.Unlock()
+		w.deliver(value)
+	}
+}
+
+// deliver replaces any version that hasn't been received yet with value.
+func (w *Watcher[T]) deliver(value T) {
+	select {
+	case <-w.updates:
+	default:
+	}
+	select {
+	case w.updates <- value:
+	default:
+	}
+}
+
+// report replaces any error that hasn't been received yet with err.
+func (w *Watcher[T]) report(err error) {
+	select {
+	case <-w.errors:
+	default:
+	}
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
@@ -0,0 +1,79 @@
+package yamlx
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	type Config struct {
+		Port int `yamlx:"port" validate:"min=1"`
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string, modTime time.Time) {
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+		assert.Nil(t, os.Chtimes(path, modTime, modTime))
+	}
+	start := time.Now()
+	write("port: 80", start)
+
+	w, err := Watch[Config](path, 5*time.Millisecond, Options{})
+	assert.Nil(t, err)
+	defer w.Close()
+	assert.Equal(t, Config{Port: 80}, w.Current())
+
+	write("port: 8080", start.Add(time.Second))
+	select {
+	case config := <-w.Updates():
+		assert.Equal(t, Config{Port: 8080}, config)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no update")
+	}
+
+	// A version that fails validation keeps the last good config
+	write("port: 0", start.Add(2*time.Second))
+	select {
+	case err := <-w.Errors():
+		assert.Contains(t, err.Error(), "port")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error")
+	}
+	assert.Equal(t, Config{Port: 8080}, w.Current())
+
+	_, err = Watch[Config](filepath.Join(t.TempDir(), "missing.yaml"), 0, Options{})
+	assert.Error(t, err)
+}
+
+func TestWatchWithoutReceiving(t *testing.T) {
+	type Config struct {
+		Port int `yamlx:"port"`
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string, modTime time.Time) {
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+		assert.Nil(t, os.Chtimes(path, modTime, modTime))
+	}
+	start := time.Now()
+	write("port: 80", start)
+
+	w, err := Watch[Config](path, 5*time.Millisecond, Options{})
+	assert.Nil(t, err)
+	defer w.Close()
+
+	// Nobody receives from Updates, and Current still follows every change
+	for i, port := range []int{81, 82, 83} {
+		write(fmt.Sprintf("port: %d", port), start.Add(time.Duration(i+1)*time.Second))
+		assert.Eventually(t, func() bool { return w.Current().Port == port }, 5*time.Second, 5*time.Millisecond)
+	}
+
+	// The latest version is still waiting on Updates
+	for config := range w.Updates() {
+		if config.Port == 83 {
+			break
+		}
+	}
+}